@@ -0,0 +1,264 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/linkedin-inc/mane/logger"
+	t "github.com/linkedin-inc/mane/template"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrUnsupportedFormat   = errors.New("unsupported config format")
+	ErrDuplicatedTemplate  = errors.New("duplicated template")
+	ErrDuplicatedCategory  = errors.New("duplicated category")
+	ErrUndefinedCategory   = errors.New("undefined category")
+	ErrMalformedConfigFile = errors.New("malformed config file")
+)
+
+//decoders turn a file into the documents it holds, only YAML may hold more than one
+var decoders = map[string]func([]byte) ([]interface{}, error){
+	".json": decodeJSON,
+	".yaml": decodeYAML,
+	".yml":  decodeYAML,
+	".toml": decodeTOML,
+}
+
+//configFile is the layout shared by all formats, keys are the json tags of SMSCategory and SMSTemplate:
+//
+//	categories:
+//	  - category: verification
+//	    channel: production
+//	templates:
+//	  - name: login
+//	    category: verification
+//	    content: "your code is {code}"
+//	    enabled: true
+//	    actions:
+//	      - name: ErrorReport
+type configFile struct {
+	Categories []t.SMSCategory `json:"categories"`
+	Templates  []t.SMSTemplate `json:"templates"`
+}
+
+//FileLoader is a ConfigLoader reading YAML, JSON or TOML files, a directory stands for every such file in it.
+//A template may refer to a category defined in another file of the same loader.
+//YAML files may use anchors and hold several documents separated by ---, TOML datetimes are read as RFC 3339 strings.
+type FileLoader struct {
+	paths []string
+}
+
+func NewFileLoader(paths ...string) *FileLoader {
+	return &FileLoader{paths: paths}
+}
+
+//Load reads and validates every file, it fails as a whole if any file is malformed or invalid
+func (l *FileLoader) Load() ([]t.SMSCategory, []t.SMSTemplate, error) {
+	files, err := l.Files()
+	if err != nil {
+		return nil, nil, err
+	}
+	var categories []t.SMSCategory
+	var templates []t.SMSTemplate
+	for _, file := range files {
+		loaded, err := loadFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		categories = append(categories, loaded.Categories...)
+		templates = append(templates, loaded.Templates...)
+	}
+	if err = validate(categories, templates); err != nil {
+		return nil, nil, err
+	}
+	for i := range templates {
+		if err = templates[i].ResolveActions(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return categories, templates, nil
+}
+
+func (l *FileLoader) LoadCategory() []t.SMSCategory {
+	categories, _, err := l.Load()
+	if err != nil {
+		logger.Error("failed to load categories", logger.Err(err))
+		return nil
+	}
+	return categories
+}
+
+func (l *FileLoader) LoadTemplate() []t.SMSTemplate {
+	_, templates, err := l.Load()
+	if err != nil {
+		logger.Error("failed to load templates", logger.Err(err))
+		return nil
+	}
+	return templates
+}
+
+//Files returns the config files behind the paths of the loader, sorted within each directory
+func (l *FileLoader) Files() ([]string, error) {
+	var files []string
+	for _, path := range l.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, entry := range entries {
+			if _, supported := decoders[strings.ToLower(filepath.Ext(entry.Name()))]; supported && !entry.IsDir() {
+				names = append(names, entry.Name())
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			files = append(files, filepath.Join(path, name))
+		}
+	}
+	return files, nil
+}
+
+func loadFile(path string) (*configFile, error) {
+	decode, supported := decoders[strings.ToLower(filepath.Ext(path))]
+	if !supported {
+		return nil, fmt.Errorf("%s: %w", path, ErrUnsupportedFormat)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	documents, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w, %v", path, ErrMalformedConfigFile, err)
+	}
+	loaded := &configFile{}
+	for i, document := range documents {
+		if document == nil {
+			continue
+		}
+		root, ok := document.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: %w, expected a mapping at top level of document %d", path, ErrMalformedConfigFile, i+1)
+		}
+		if err = normalizeChannels(root); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		//every format decodes to the same generic values, json tags then do the mapping
+		data, err = json.Marshal(root)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w, %v", path, ErrMalformedConfigFile, err)
+		}
+		var part configFile
+		if err = json.Unmarshal(data, &part); err != nil {
+			return nil, fmt.Errorf("%s: %w, %v", path, ErrMalformedConfigFile, err)
+		}
+		loaded.Categories = append(loaded.Categories, part.Categories...)
+		loaded.Templates = append(loaded.Templates, part.Templates...)
+	}
+	return loaded, nil
+}
+
+func decodeJSON(data []byte) ([]interface{}, error) {
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	return []interface{}{decoded}, nil
+}
+
+func decodeYAML(data []byte) ([]interface{}, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	var documents []interface{}
+	for {
+		var document interface{}
+		err := decoder.Decode(&document)
+		if err == io.EOF {
+			return documents, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+}
+
+//decodeTOML goes through JSON so that arrays of tables and datetimes become the generic values the other formats decode to
+func decodeTOML(data []byte) ([]interface{}, error) {
+	var document map[string]interface{}
+	if _, err := toml.Decode(string(data), &document); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSON(data)
+}
+
+//normalizeChannels lets channels be written by name, e.g. "marketing", as well as by number
+func normalizeChannels(root map[string]interface{}) error {
+	categories, _ := root["categories"].([]interface{})
+	for _, item := range categories {
+		category, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, ok := category["channel"].(string)
+		if !ok {
+			continue
+		}
+		channel := t.WhichChannel(name)
+		if channel == t.UnknownChannel {
+			return fmt.Errorf("category %v: %w %s", category["category"], t.ErrUnknownChannel, name)
+		}
+		category["channel"] = int(channel)
+	}
+	return nil
+}
+
+//validate checks every category and template, that names are unique and that templates refer to defined categories
+func validate(categories []t.SMSCategory, templates []t.SMSTemplate) error {
+	defined := make(map[t.Category]bool, len(categories))
+	for _, category := range categories {
+		if err := category.Validate(); err != nil {
+			return err
+		}
+		if defined[category.Name] {
+			return fmt.Errorf("%w %s", ErrDuplicatedCategory, category.Name)
+		}
+		defined[category.Name] = true
+	}
+	names := make(map[t.Name]bool, len(templates))
+	for _, template := range templates {
+		if err := template.Validate(); err != nil {
+			return err
+		}
+		if names[template.Name] {
+			return fmt.Errorf("%w %s", ErrDuplicatedTemplate, template.Name)
+		}
+		names[template.Name] = true
+		if !defined[template.Category] {
+			return fmt.Errorf("template %s: %w %s", template.Name, ErrUndefinedCategory, template.Category)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/linkedin-inc/mane/middleware"
+	tp "github.com/linkedin-inc/mane/template"
+)
+
+const yamlConfig = `
+# categories first
+categories:
+  - category: verification
+    channel: production
+    description: "codes: login and signup"
+  - {category: promotion, channel: 1}
+templates:
+- name: login
+  category: verification
+  content: 您的验证码是{code} # trailing comment
+  enabled: true
+  sensitive: [code]
+  actions:
+    - name: ErrorReport
+- name: sale
+  category: promotion
+  enabled: false
+  content: |-
+    {name}, sale starts today
+    reply TD to unsubscribe
+`
+
+const jsonConfig = `{
+  "categories": [
+    {"category": "verification", "channel": "production", "description": "codes: login and signup"},
+    {"category": "promotion", "channel": 1}
+  ],
+  "templates": [
+    {"name": "login", "category": "verification", "content": "您的验证码是{code}", "enabled": true,
+     "sensitive": ["code"], "actions": [{"name": "ErrorReport"}]},
+    {"name": "sale", "category": "promotion", "enabled": false,
+     "content": "{name}, sale starts today\nreply TD to unsubscribe"}
+  ]
+}`
+
+const tomlConfig = `
+[[categories]]
+category = "verification"
+channel = "production"
+description = "codes: login and signup"
+
+[[categories]]
+category = "promotion"
+channel = 1
+
+[[templates]]
+name = "login"
+category = "verification"
+content = "您的验证码是{code}" # trailing comment
+enabled = true
+sensitive = ["code"]
+actions = [{name = "ErrorReport"}]
+
+[[templates]]
+name = "sale"
+category = "promotion"
+enabled = false
+content = """
+{name}, sale starts today
+reply TD to unsubscribe"""
+`
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v\n", path, err)
+	}
+	return path
+}
+
+func init() {
+	tp.RegisterAction("ErrorReport", middleware.NewErrorReport())
+}
+
+func TestFileLoader_Formats(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mane")
+	defer os.RemoveAll(dir)
+	var expectedCategories []tp.SMSCategory
+	var expectedTemplates []tp.SMSTemplate
+	for i, name := range []string{"sms.yaml", "sms.json", "sms.toml"} {
+		content := []string{yamlConfig, jsonConfig, tomlConfig}[i]
+		categories, templates, err := NewFileLoader(writeConfig(t, dir, name, content)).Load()
+		if err != nil {
+			t.Fatalf("TestFileLoader_Formats %s failed. err:%v\n", name, err)
+		}
+		if len(categories) != 2 || categories[0].Channel != tp.ProductionChannel || categories[1].Channel != tp.MarketingChannel {
+			t.Fatalf("TestFileLoader_Formats %s failed. categories:%v\n", name, categories)
+		}
+		if len(templates) != 2 || templates[1].Content != "{name}, sale starts today\nreply TD to unsubscribe" {
+			t.Fatalf("TestFileLoader_Formats %s failed. templates:%v\n", name, templates)
+		}
+		if i == 0 {
+			expectedCategories, expectedTemplates = categories, templates
+			continue
+		}
+		if !reflect.DeepEqual(categories, expectedCategories) || !reflect.DeepEqual(templates, expectedTemplates) {
+			t.Fatalf("TestFileLoader_Formats %s failed. differs from yaml:\n%v\n%v\n", name, templates, expectedTemplates)
+		}
+	}
+}
+
+func TestFileLoader_Validation(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mane")
+	defer os.RemoveAll(dir)
+	cases := []struct {
+		content string
+		err     error
+	}{
+		{"categories:\n  - category: a\n    channel: 9\n", tp.ErrUnknownChannel},
+		{"categories:\n  - category: a\n    channel: space\n", tp.ErrUnknownChannel},
+		{"templates:\n  - name: a\n    category: a\n    content: hi\n", ErrUndefinedCategory},
+		{"categories:\n  - {category: a, channel: 1}\ntemplates:\n  - {name: a, category: a}\n", tp.ErrEmptyContent},
+		{"categories:\n  - {category: a, channel: 1}\ntemplates:\n  - {name: a, category: a, content: \"hi {code\"}\n", tp.ErrMalformedVariables},
+		{"categories:\n  - {category: a, channel: 1}\ntemplates:\n  - name: a\n    category: a\n    content: hi\n    actions: [{name: Missing}]\n", tp.ErrUnknownAction},
+		{"categories:\n  - {category: a, channel: 1}\n  - {category: a, channel: 2}\n", ErrDuplicatedCategory},
+		{"templates: 3\n", ErrMalformedConfigFile},
+		{"- a\n- b\n", ErrMalformedConfigFile},
+	}
+	for i, c := range cases {
+		_, _, err := NewFileLoader(writeConfig(t, dir, "case.yaml", c.content)).Load()
+		if !errors.Is(err, c.err) {
+			t.Errorf("TestFileLoader_Validation case %d failed. expected:%v, actual:%v\n", i, c.err, err)
+		}
+	}
+	if _, _, err := NewFileLoader(writeConfig(t, dir, "case.ini", "")).Load(); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("TestFileLoader_Validation failed. err:%v\n", err)
+	}
+	if _, _, err := NewFileLoader(writeConfig(t, dir, "case.yaml", "a:\n  b: 1\n c: 2\n")).Load(); !errors.Is(err, ErrMalformedConfigFile) {
+		t.Errorf("TestFileLoader_Validation failed. bad indentation, err:%v\n", err)
+	}
+	if _, _, err := NewFileLoader(writeConfig(t, dir, "case.yaml", "categories: [{category: a\n")).Load(); !errors.Is(err, ErrMalformedConfigFile) {
+		t.Errorf("TestFileLoader_Validation failed. unclosed flow mapping, err:%v\n", err)
+	}
+	if _, _, err := NewFileLoader(writeConfig(t, dir, "case.yaml", "categories: *missing\n")).Load(); !errors.Is(err, ErrMalformedConfigFile) {
+		t.Errorf("TestFileLoader_Validation failed. unknown anchor, err:%v\n", err)
+	}
+	if _, _, err := NewFileLoader(writeConfig(t, dir, "case.yaml", "categories: []\n---\n- a\n")).Load(); !errors.Is(err, ErrMalformedConfigFile) {
+		t.Errorf("TestFileLoader_Validation failed. sequence document, err:%v\n", err)
+	}
+	if _, _, err := NewFileLoader(writeConfig(t, dir, "case.toml", "a = \"open\n")).Load(); !errors.Is(err, ErrMalformedConfigFile) {
+		t.Errorf("TestFileLoader_Validation failed. unterminated string, err:%v\n", err)
+	}
+	if _, _, err := NewFileLoader(writeConfig(t, dir, "case.toml", "a = 1\na = 2\n")).Load(); !errors.Is(err, ErrMalformedConfigFile) {
+		t.Errorf("TestFileLoader_Validation failed. duplicated key, err:%v\n", err)
+	}
+	if _, _, err := NewFileLoader(writeConfig(t, dir, "case.json", "{\"categories\": [}")).Load(); !errors.Is(err, ErrMalformedConfigFile) {
+		t.Errorf("TestFileLoader_Validation failed. broken json, err:%v\n", err)
+	}
+}
+
+func TestFileLoader_YAMLDocuments(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mane")
+	defer os.RemoveAll(dir)
+	content := `
+categories:
+  - {category: verification, channel: production}
+---
+templates:
+  - &base
+    name: login
+    category: verification
+    content: 您的验证码是{code}
+    enabled: true
+  - <<: *base
+    name: signup
+---
+`
+	categories, templates, err := NewFileLoader(writeConfig(t, dir, "sms.yaml", content)).Load()
+	if err != nil {
+		t.Fatalf("TestFileLoader_YAMLDocuments failed. err:%v\n", err)
+	}
+	if len(categories) != 1 || len(templates) != 2 {
+		t.Fatalf("TestFileLoader_YAMLDocuments failed. categories:%v, templates:%v\n", categories, templates)
+	}
+	if templates[1].Name != "signup" || templates[1].Content != templates[0].Content || !templates[1].Enabled {
+		t.Fatalf("TestFileLoader_YAMLDocuments failed. anchor not merged:%v\n", templates[1])
+	}
+}
+
+func TestFileLoader_TOMLDatetime(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mane")
+	defer os.RemoveAll(dir)
+	content := "[[categories]]\ncategory = \"a\"\nchannel = 1\ndescription = 2024-05-01T08:00:00Z\n"
+	categories, _, err := NewFileLoader(writeConfig(t, dir, "sms.toml", content)).Load()
+	if err != nil || len(categories) != 1 || categories[0].Description != "2024-05-01T08:00:00Z" {
+		t.Fatalf("TestFileLoader_TOMLDatetime failed. categories:%v, err:%v\n", categories, err)
+	}
+}
+
+func TestFileLoader_Directory(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mane")
+	defer os.RemoveAll(dir)
+	writeConfig(t, dir, "1-categories.json", `{"categories": [{"category": "a", "channel": "internal"}]}`)
+	writeConfig(t, dir, "2-templates.yml", "templates:\n  - {name: a, category: a, content: hi, enabled: true}\n")
+	writeConfig(t, dir, "README.md", "not a config")
+	categories, templates, err := NewFileLoader(dir).Load()
+	if err != nil || len(categories) != 1 || len(templates) != 1 || templates[0].Category != categories[0].Name {
+		t.Fatalf("TestFileLoader_Directory failed. categories:%v, templates:%v, err:%v\n", categories, templates, err)
+	}
+	writeConfig(t, dir, "3-again.toml", "[[templates]]\nname = \"a\"\ncategory = \"a\"\ncontent = \"hi\"\n")
+	if _, _, err = NewFileLoader(dir).Load(); !errors.Is(err, ErrDuplicatedTemplate) {
+		t.Fatalf("TestFileLoader_Directory failed. err:%v\n", err)
+	}
+}
+
+func TestFileWatcher(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mane")
+	defer os.RemoveAll(dir)
+	writeConfig(t, dir, "sms.yaml", yamlConfig)
+	watcher := NewFileWatcher(10*time.Millisecond, dir)
+	defer watcher.Stop()
+	changes := make(chan int64, 1)
+	go watcher.Watch(changes)
+
+	time.Sleep(30 * time.Millisecond)
+	select {
+	case <-changes:
+		t.Fatalf("TestFileWatcher failed. change reported without any\n")
+	default:
+	}
+	writeConfig(t, dir, "more.json", "{}")
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatalf("TestFileWatcher failed. no change reported\n")
+	}
+	//the deferred Stop must not panic on the closed channel
+	watcher.Stop()
+}
@@ -2,28 +2,38 @@ package config
 
 import (
 	"errors"
+	"time"
 
 	"github.com/linkedin-inc/mane/logger"
 	t "github.com/linkedin-inc/mane/template"
+	u "github.com/linkedin-inc/mane/util"
 )
 
 type SMSConfig struct {
 	Username  string
 	Password  string
 	Endpoints []string
+	//MaxInFlight limits concurrent sends through the account, channels sharing an account share it, 0 means use the vendor default
+	MaxInFlight int
+	//QPS limits how many requests per second are made to the vendor, 0 means use the vendor default
+	QPS int
+	//Retry overrides the retry policy of the vendor, nil means use the vendor default
+	Retry *u.RetryPolicy
+	//BreakerThreshold is how many consecutive failures open a circuit of the vendor, each endpoint has its own, 0 means use the default
+	BreakerThreshold int
+	//BreakerCooldown is how long an open circuit rejects requests before probing, 0 means use the default
+	BreakerCooldown time.Duration
+	//Reserved is how many slots of the shared vendor pool only this channel may use, 0 means use the default
+	Reserved int
+	//Quota is how many slots of the shared vendor pool this channel may use at most, 0 means use the default
+	Quota int
 }
 
 var (
 	ErrTemplateNotFound     = errors.New("template not found")
 	ErrCategoryNotFound     = errors.New("category not found")
 	ErrTemplateNotAvailable = errors.New("template not available")
-
-	//短信类别
-	LoadedCategories = make(map[t.Category]t.SMSCategory)
-	//短信通道
-	LoadedChannels = make(map[t.Category]t.Channel)
-	//短信模版
-	LoadedTemplates = make(map[t.Name]t.SMSTemplate)
+	ErrEmptyConfig          = errors.New("empty config")
 
 	hole = make(chan int64, 1)
 )
@@ -35,15 +45,41 @@ const (
 
 //Load configuration
 func Init() {
-	load()
+	if loader == nil {
+		panic("config loader not registered, call RegisterLoader, e.g. with NewFileLoader")
+	}
+	_ = load()
 	go watch()
 	go reload()
 }
 
-func load() {
-	loadTemplate()
-	loadCategory()
-	logger.I("loaded template: %v\nloaded category: %v\n", LoadedTemplates, LoadedCategories)
+//load builds a new snapshot from the registered loader, the active one is kept if anything goes wrong
+func load() error {
+	var categories []t.SMSCategory
+	var templates []t.SMSTemplate
+	if l, ok := loader.(snapshotLoader); ok {
+		var err error
+		if categories, templates, err = l.Load(); err != nil {
+			logger.Error("failed to load config, keep the active one", logger.Any("version", Current().Version), logger.Err(err))
+			return err
+		}
+	} else {
+		categories = loader.LoadCategory()
+		templates = loader.LoadTemplate()
+	}
+	if len(categories) == 0 || len(templates) == 0 {
+		logger.Error("loaded config seems empty, keep the active one", logger.Any("version", Current().Version),
+			logger.Any("categories", len(categories)), logger.Any("templates", len(templates)))
+		return ErrEmptyConfig
+	}
+	snapshot, err := Apply(categories, templates)
+	if err != nil {
+		logger.Error("invalid config, keep the active one", logger.Any("version", Current().Version), logger.Err(err))
+		return err
+	}
+	logger.Info("config loaded", logger.Any("version", snapshot.Version),
+		logger.Any("categories", len(categories)), logger.Any("templates", len(templates)))
+	return nil
 }
 
 type Watcher interface {
@@ -57,13 +93,17 @@ func RegisterWatcher(w Watcher) {
 }
 
 func watch() {
+	//nothing triggers a reload without a watcher
+	if watcher == nil {
+		return
+	}
 	watcher.Watch(hole)
 }
 
 func reload() {
 	for {
 		_ = <-hole
-		load()
+		_ = load()
 	}
 }
 
@@ -72,63 +112,27 @@ type ConfigLoader interface {
 	LoadTemplate() []t.SMSTemplate
 }
 
+//snapshotLoader loads categories and templates at once and reports failures, FileLoader is one
+type snapshotLoader interface {
+	Load() ([]t.SMSCategory, []t.SMSTemplate, error)
+}
+
 var loader ConfigLoader
 
 func RegisterLoader(configLoader ConfigLoader) {
 	loader = configLoader
 }
 
-func loadCategory() {
-	LoadedCategories = make(map[t.Category]t.SMSCategory)
-	categories := loader.LoadCategory()
-	if len(categories) == 0 {
-		logger.E("loaded category: %v, it seems empty, are you sure?", categories)
-		return
-	}
-	for _, category := range categories {
-		LoadedChannels[category.Name] = category.Channel
-		LoadedCategories[category.Name] = category
-	}
-}
-
-func loadTemplate() {
-	LoadedTemplates = make(map[t.Name]t.SMSTemplate)
-	templates := loader.LoadTemplate()
-	if len(templates) == 0 {
-		logger.E("loaded template: %v, it seems empty, are you sure?", templates)
-		return
-	}
-	for _, template := range templates {
-		LoadedTemplates[template.Name] = template
-	}
-}
-
 //WhichChannel returns a channel for given category
 func WhichChannel(name t.Category) (t.Channel, error) {
-	channel, existed := LoadedChannels[name]
-	if !existed {
-		return t.UnknownChannel, ErrCategoryNotFound
-	}
-	return channel, nil
+	return Current().Channel(name)
 }
 
 //WhichTemplate returns the enabled template for given name
 func WhichTemplate(name t.Name) (*t.SMSTemplate, error) {
-	smsTemplate, existed := LoadedTemplates[name]
-	if !existed {
-		return nil, ErrTemplateNotFound
-	}
-	//only return enabled template
-	if !smsTemplate.Enabled {
-		return nil, ErrTemplateNotAvailable
-	}
-	return &smsTemplate, nil
+	return Current().Template(name)
 }
 
 func WhichCategory(name t.Category) (*t.SMSCategory, error) {
-	smsCategory, existed := LoadedCategories[name]
-	if !existed {
-		return nil, ErrCategoryNotFound
-	}
-	return &smsCategory, nil
+	return Current().Category(name)
 }
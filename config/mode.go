@@ -0,0 +1,101 @@
+package config
+
+import (
+	"os"
+	"sync"
+
+	"github.com/linkedin-inc/mane/logger"
+)
+
+//RunMode decides whether and where messages are really sent
+type RunMode int
+
+const (
+	//send through registered vendors
+	ModeProduction RunMode = iota
+	//send through the in-process sandbox vendor, which records messages and makes up delivery statuses
+	ModeSandbox
+	//go through the whole pipeline but never call any vendor, every message is reported as sent
+	ModeDryRun
+	//send whitelisted phones like ModeProduction and treat others like ModeDryRun, meant for staging
+	ModeWhitelist
+)
+
+func (mode RunMode) String() string {
+	switch mode {
+	case ModeProduction:
+		return "production"
+	case ModeSandbox:
+		return "sandbox"
+	case ModeDryRun:
+		return "dry-run"
+	case ModeWhitelist:
+		return "whitelist"
+	default:
+		return "unknown"
+	}
+}
+
+type runModeHolder struct {
+	locker    *sync.RWMutex
+	mode      RunMode
+	whitelist map[string]bool
+}
+
+var runMode runModeHolder
+
+func init() {
+	mode := defaultRunMode()
+	runMode = runModeHolder{
+		locker:    new(sync.RWMutex),
+		mode:      mode,
+		whitelist: make(map[string]bool),
+	}
+	configureLogger(mode)
+}
+
+//defaultRunMode is the run mode until SetRunMode is called: dry run, or production when CHITU_ENV is production.
+//
+// Deprecated: the CHITU_ENV fallback only keeps hosts that never call SetRunMode sending, it will be removed.
+func defaultRunMode() RunMode {
+	if os.Getenv("CHITU_ENV") == "production" {
+		return ModeProduction
+	}
+	return ModeDryRun
+}
+
+//SetRunMode switches the run mode, whitelist replaces the phones really sent to in ModeWhitelist.
+//It configures the logger too: ModeProduction logs at info level with masking, other modes at debug level without,
+//call logger.SetLevel or logger.SetMasking afterwards to override.
+func SetRunMode(mode RunMode, whitelist ...string) {
+	runMode.locker.Lock()
+	defer runMode.locker.Unlock()
+	configureLogger(mode)
+	runMode.mode = mode
+	runMode.whitelist = make(map[string]bool, len(whitelist))
+	for _, phone := range whitelist {
+		runMode.whitelist[phone] = true
+	}
+}
+
+func configureLogger(mode RunMode) {
+	if mode == ModeProduction {
+		logger.SetLevel(logger.LevelInfo)
+	} else {
+		logger.SetLevel(logger.LevelDebug)
+	}
+	logger.SetMasking(mode == ModeProduction)
+}
+
+func CurrentRunMode() RunMode {
+	runMode.locker.RLock()
+	defer runMode.locker.RUnlock()
+	return runMode.mode
+}
+
+//Whitelisted tells whether phone is really sent to in ModeWhitelist
+func Whitelisted(phone string) bool {
+	runMode.locker.RLock()
+	defer runMode.locker.RUnlock()
+	return runMode.whitelist[phone]
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/linkedin-inc/mane/logger"
+)
+
+func TestSetRunMode_Logger(t *testing.T) {
+	defer SetRunMode(ModeDryRun)
+	if CurrentRunMode() != ModeDryRun || logger.MaskingEnabled() {
+		t.Fatalf("TestSetRunMode_Logger failed. default mode:%v\n", CurrentRunMode())
+	}
+	SetRunMode(ModeProduction)
+	if !logger.MaskingEnabled() || logger.GetLevel() != logger.LevelInfo {
+		t.Fatalf("TestSetRunMode_Logger failed. production level:%v\n", logger.GetLevel())
+	}
+	SetRunMode(ModeWhitelist, "13800000000")
+	if logger.MaskingEnabled() || logger.GetLevel() != logger.LevelDebug || !Whitelisted("13800000000") {
+		t.Fatalf("TestSetRunMode_Logger failed. whitelist level:%v\n", logger.GetLevel())
+	}
+}
+
+func TestDefaultRunMode(t *testing.T) {
+	defer os.Unsetenv("CHITU_ENV")
+	os.Setenv("CHITU_ENV", "production")
+	if mode := defaultRunMode(); mode != ModeProduction {
+		t.Fatalf("TestDefaultRunMode failed. mode:%v\n", mode)
+	}
+	os.Setenv("CHITU_ENV", "staging")
+	if mode := defaultRunMode(); mode != ModeDryRun {
+		t.Fatalf("TestDefaultRunMode failed. mode:%v\n", mode)
+	}
+}
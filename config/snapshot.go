@@ -0,0 +1,257 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	t "github.com/linkedin-inc/mane/template"
+)
+
+//Snapshot is a complete configuration as loaded at once, it is never modified after being built
+type Snapshot struct {
+	//Version increases by one with every snapshot applied, 0 means nothing was loaded yet
+	Version int64
+	//Timestamp is when the snapshot was applied
+	Timestamp time.Time
+
+	categories map[t.Category]t.SMSCategory
+	templates  map[t.Name]t.SMSTemplate
+	//versions of each template seen so far, oldest first, the last one is in templates
+	versions  map[t.Name][]t.SMSTemplate
+	rollbacks map[t.Name]rollback
+}
+
+type rollback struct {
+	//version served instead of the latest
+	version int64
+	//latest version when rolled back, a newer one published afterwards ends the rollback
+	from int64
+}
+
+var ErrVersionNotFound = errors.New("template version not found")
+
+//MaxTemplateVersions is how many versions of each template are kept for pinning and rollback
+var MaxTemplateVersions = 20
+
+var (
+	active atomic.Value
+	//serializes building and swapping so versions are applied in order
+	applyLocker sync.Mutex
+)
+
+func init() {
+	active.Store(&Snapshot{
+		categories: make(map[t.Category]t.SMSCategory),
+		templates:  make(map[t.Name]t.SMSTemplate),
+		versions:   make(map[t.Name][]t.SMSTemplate),
+		rollbacks:  make(map[t.Name]rollback),
+	})
+}
+
+//Current returns the active snapshot, keep using the returned one for lookups that must agree with each other
+func Current() *Snapshot {
+	return active.Load().(*Snapshot)
+}
+
+//Apply validates categories and templates and makes them the active snapshot,
+//the active snapshot is left untouched if they are invalid.
+//Templates changed since the active snapshot are added to their version history, a template without
+//timestamp is stamped with the time it was first seen changed.
+func Apply(categories []t.SMSCategory, templates []t.SMSTemplate) (*Snapshot, error) {
+	if err := validate(categories, templates); err != nil {
+		return nil, err
+	}
+	applyLocker.Lock()
+	defer applyLocker.Unlock()
+	previous := Current()
+	snapshot := previous.next(len(templates))
+	snapshot.categories = make(map[t.Category]t.SMSCategory, len(categories))
+	for _, category := range categories {
+		snapshot.categories[category.Name] = category
+	}
+	snapshot.templates = make(map[t.Name]t.SMSTemplate, len(templates))
+	snapshot.versions = make(map[t.Name][]t.SMSTemplate, len(templates))
+	for _, template := range templates {
+		versions := previous.versions[template.Name]
+		if len(versions) == 0 || changed(versions[len(versions)-1], template) {
+			if template.Timestamp == 0 {
+				template.Timestamp = snapshot.Timestamp.Unix()
+			}
+			if len(versions) > 0 && template.Timestamp <= versions[len(versions)-1].Timestamp {
+				template.Timestamp = versions[len(versions)-1].Timestamp + 1
+			}
+			versions = appendVersion(versions, template)
+		} else {
+			//unchanged, keep the version stamped before
+			template.Timestamp = versions[len(versions)-1].Timestamp
+		}
+		snapshot.templates[template.Name] = template
+		snapshot.versions[template.Name] = versions
+	}
+	for name, rb := range previous.rollbacks {
+		if template, existed := snapshot.templates[name]; existed && template.Version() == rb.from {
+			snapshot.rollbacks[name] = rb
+		}
+	}
+	active.Store(snapshot)
+	return snapshot, nil
+}
+
+//Rollback makes the active snapshot serve given version of a template instead of the latest one,
+//until a newer version is published or CancelRollback is called.
+func Rollback(name t.Name, version int64) (*Snapshot, error) {
+	applyLocker.Lock()
+	defer applyLocker.Unlock()
+	previous := Current()
+	if _, err := previous.version(name, version); err != nil {
+		return nil, err
+	}
+	snapshot := previous.next(0)
+	for n, rb := range previous.rollbacks {
+		snapshot.rollbacks[n] = rb
+	}
+	snapshot.rollbacks[name] = rollback{version: version, from: previous.templates[name].Version()}
+	active.Store(snapshot)
+	return snapshot, nil
+}
+
+//CancelRollback makes the active snapshot serve the latest version of a template again
+func CancelRollback(name t.Name) *Snapshot {
+	applyLocker.Lock()
+	defer applyLocker.Unlock()
+	previous := Current()
+	snapshot := previous.next(0)
+	for n, rb := range previous.rollbacks {
+		if n != name {
+			snapshot.rollbacks[n] = rb
+		}
+	}
+	active.Store(snapshot)
+	return snapshot
+}
+
+//next returns a snapshot sharing categories, templates and versions with s but with its own rollbacks,
+//the caller must hold applyLocker and replace any map it modifies.
+func (s *Snapshot) next(size int) *Snapshot {
+	return &Snapshot{
+		Version:    s.Version + 1,
+		Timestamp:  time.Now(),
+		categories: s.categories,
+		templates:  s.templates,
+		versions:   s.versions,
+		rollbacks:  make(map[t.Name]rollback, size),
+	}
+}
+
+//changed tells whether template differs from the version before, the timestamp is left out
+//since loaders without one leave it empty on every load.
+func changed(before, template t.SMSTemplate) bool {
+	if template.Timestamp != 0 && template.Timestamp != before.Timestamp {
+		return true
+	}
+	before.Timestamp, template.Timestamp = 0, 0
+	before.ActionList, template.ActionList = nil, nil
+	return !reflect.DeepEqual(before, template)
+}
+
+func appendVersion(versions []t.SMSTemplate, template t.SMSTemplate) []t.SMSTemplate {
+	if len(versions) >= MaxTemplateVersions {
+		versions = versions[len(versions)-MaxTemplateVersions+1:]
+	}
+	//never append in place, older snapshots may share the backing array
+	appended := make([]t.SMSTemplate, len(versions), len(versions)+1)
+	copy(appended, versions)
+	return append(appended, template)
+}
+
+//Channel returns the channel of given category
+func (s *Snapshot) Channel(name t.Category) (t.Channel, error) {
+	category, existed := s.categories[name]
+	if !existed {
+		return t.UnknownChannel, ErrCategoryNotFound
+	}
+	return category.Channel, nil
+}
+
+//Template returns the enabled template for given name, the rolled back version if any
+func (s *Snapshot) Template(name t.Name) (*t.SMSTemplate, error) {
+	template, existed := s.templates[name]
+	if !existed {
+		return nil, ErrTemplateNotFound
+	}
+	if rb, rolledBack := s.rollbacks[name]; rolledBack {
+		if version, err := s.version(name, rb.version); err == nil {
+			template = *version
+		}
+	}
+	//only return enabled template
+	if !template.Enabled {
+		return nil, ErrTemplateNotAvailable
+	}
+	return &template, nil
+}
+
+//TemplateVersion returns given version of a template, it has to be enabled in that version
+func (s *Snapshot) TemplateVersion(name t.Name, version int64) (*t.SMSTemplate, error) {
+	template, err := s.version(name, version)
+	if err != nil {
+		return nil, err
+	}
+	if !template.Enabled {
+		return nil, ErrTemplateNotAvailable
+	}
+	return template, nil
+}
+
+func (s *Snapshot) version(name t.Name, version int64) (*t.SMSTemplate, error) {
+	versions, existed := s.versions[name]
+	if !existed {
+		return nil, ErrTemplateNotFound
+	}
+	for i := range versions {
+		if versions[i].Version() == version {
+			template := versions[i]
+			return &template, nil
+		}
+	}
+	return nil, ErrVersionNotFound
+}
+
+//Versions returns the versions kept of a template, oldest first
+func (s *Snapshot) Versions(name t.Name) []t.SMSTemplate {
+	return append([]t.SMSTemplate(nil), s.versions[name]...)
+}
+
+//RolledBack tells which version of a template is served instead of the latest one, false if none
+func (s *Snapshot) RolledBack(name t.Name) (int64, bool) {
+	rb, rolledBack := s.rollbacks[name]
+	return rb.version, rolledBack
+}
+
+func (s *Snapshot) Category(name t.Category) (*t.SMSCategory, error) {
+	category, existed := s.categories[name]
+	if !existed {
+		return nil, ErrCategoryNotFound
+	}
+	return &category, nil
+}
+
+//Templates returns every template including disabled ones
+func (s *Snapshot) Templates() []t.SMSTemplate {
+	templates := make([]t.SMSTemplate, 0, len(s.templates))
+	for _, template := range s.templates {
+		templates = append(templates, template)
+	}
+	return templates
+}
+
+func (s *Snapshot) Categories() []t.SMSCategory {
+	categories := make([]t.SMSCategory, 0, len(s.categories))
+	for _, category := range s.categories {
+		categories = append(categories, category)
+	}
+	return categories
+}
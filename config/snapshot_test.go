@@ -0,0 +1,154 @@
+package config
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	tp "github.com/linkedin-inc/mane/template"
+)
+
+type stubLoader struct {
+	categories []tp.SMSCategory
+	templates  []tp.SMSTemplate
+}
+
+func (s *stubLoader) LoadCategory() []tp.SMSCategory {
+	return s.categories
+}
+
+func (s *stubLoader) LoadTemplate() []tp.SMSTemplate {
+	return s.templates
+}
+
+func TestSnapshot_Reload(t *testing.T) {
+	stub := &stubLoader{
+		categories: []tp.SMSCategory{{Name: "a", Channel: tp.ProductionChannel}},
+		templates:  []tp.SMSTemplate{{Name: "a", Category: "a", Content: "v1", Enabled: true}},
+	}
+	RegisterLoader(stub)
+	defer RegisterLoader(nil)
+	if err := load(); err != nil {
+		t.Fatalf("TestSnapshot_Reload failed. err:%v\n", err)
+	}
+	first := Current()
+	if template, err := WhichTemplate("a"); err != nil || template.Content != "v1" || first.Timestamp.IsZero() {
+		t.Fatalf("TestSnapshot_Reload failed. template:%v, err:%v\n", template, err)
+	}
+
+	//an empty or invalid load keeps the active snapshot
+	stub.templates = nil
+	if err := load(); err != ErrEmptyConfig || Current() != first {
+		t.Fatalf("TestSnapshot_Reload failed. empty load err:%v\n", err)
+	}
+	stub.templates = []tp.SMSTemplate{{Name: "a", Category: "missing", Content: "v2", Enabled: true}}
+	if err := load(); !errors.Is(err, ErrUndefinedCategory) || Current() != first {
+		t.Fatalf("TestSnapshot_Reload failed. invalid load err:%v\n", err)
+	}
+
+	//categories dropped by a reload are gone, not kept around
+	stub.categories = []tp.SMSCategory{{Name: "b", Channel: tp.MarketingChannel}}
+	stub.templates = []tp.SMSTemplate{{Name: "a", Category: "b", Content: "v3", Enabled: true}}
+	if err := load(); err != nil || Current().Version != first.Version+1 {
+		t.Fatalf("TestSnapshot_Reload failed. version:%d, err:%v\n", Current().Version, err)
+	}
+	if _, err := WhichChannel("a"); err != ErrCategoryNotFound {
+		t.Fatalf("TestSnapshot_Reload failed. stale channel, err:%v\n", err)
+	}
+	if template, _ := first.Template("a"); template.Content != "v1" {
+		t.Fatalf("TestSnapshot_Reload failed. old snapshot modified:%v\n", template)
+	}
+}
+
+func TestSnapshot_ConcurrentReload(t *testing.T) {
+	categories := []tp.SMSCategory{{Name: "a", Channel: tp.ProductionChannel}}
+	templates := []tp.SMSTemplate{{Name: "a", Category: "a", Content: "hi", Enabled: true}}
+	if _, err := Apply(categories, templates); err != nil {
+		t.Fatalf("TestSnapshot_ConcurrentReload failed. err:%v\n", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _ = Apply(categories, templates)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				snapshot := Current()
+				template, err := snapshot.Template("a")
+				if err != nil {
+					t.Errorf("TestSnapshot_ConcurrentReload failed. err:%v\n", err)
+					return
+				}
+				if _, err = snapshot.Channel(template.Category); err != nil {
+					t.Errorf("TestSnapshot_ConcurrentReload failed. err:%v\n", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSnapshot_Versions(t *testing.T) {
+	categories := []tp.SMSCategory{{Name: "a", Channel: tp.ProductionChannel}}
+	apply := func(content string, timestamp int64) {
+		templates := []tp.SMSTemplate{{Name: "v", Category: "a", Content: content, Enabled: true, Timestamp: timestamp}}
+		if _, err := Apply(categories, templates); err != nil {
+			t.Fatalf("TestSnapshot_Versions failed. err:%v\n", err)
+		}
+	}
+	apply("v1", 100)
+	apply("v1", 100)
+	apply("v2", 200)
+	//loaders without timestamps get one stamped
+	apply("v3", 0)
+	apply("v3", 0)
+	versions := Current().Versions("v")
+	if len(versions) != 3 || versions[0].Version() != 100 || versions[1].Version() != 200 || versions[2].Version() <= 200 {
+		t.Fatalf("TestSnapshot_Versions failed. versions:%v\n", versions)
+	}
+	latest := versions[2].Version()
+
+	if template, err := Current().TemplateVersion("v", 100); err != nil || template.Content != "v1" {
+		t.Fatalf("TestSnapshot_Versions failed. pinned:%v, err:%v\n", template, err)
+	}
+	if _, err := Current().TemplateVersion("v", 150); err != ErrVersionNotFound {
+		t.Fatalf("TestSnapshot_Versions failed. err:%v\n", err)
+	}
+
+	if _, err := Rollback("v", 200); err != nil {
+		t.Fatalf("TestSnapshot_Versions failed. err:%v\n", err)
+	}
+	if template, _ := WhichTemplate("v"); template.Content != "v2" {
+		t.Fatalf("TestSnapshot_Versions failed. rolled back:%v\n", template)
+	}
+	//reloading the same latest version keeps the rollback, publishing a newer one ends it
+	apply("v3", 0)
+	if version, rolledBack := Current().RolledBack("v"); !rolledBack || version != 200 {
+		t.Fatalf("TestSnapshot_Versions failed. rollback lost:%d\n", version)
+	}
+	apply("v4", latest+10)
+	if template, _ := WhichTemplate("v"); template.Content != "v4" {
+		t.Fatalf("TestSnapshot_Versions failed. rollback not ended:%v\n", template)
+	}
+
+	if _, err := Rollback("v", 100); err != nil {
+		t.Fatalf("TestSnapshot_Versions failed. err:%v\n", err)
+	}
+	CancelRollback("v")
+	if template, _ := WhichTemplate("v"); template.Content != "v4" {
+		t.Fatalf("TestSnapshot_Versions failed. rollback not cancelled:%v\n", template)
+	}
+
+	MaxTemplateVersions = 2
+	defer func() { MaxTemplateVersions = 20 }()
+	apply("v5", latest+20)
+	if versions = Current().Versions("v"); len(versions) != 2 || versions[0].Content != "v4" {
+		t.Fatalf("TestSnapshot_Versions failed. versions:%v\n", versions)
+	}
+}
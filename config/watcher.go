@@ -0,0 +1,78 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkedin-inc/mane/logger"
+)
+
+//FileWatcher is a Watcher polling the files a FileLoader reads from, any file added, removed or modified
+//triggers a reload. Polling keeps it free of platform specific notification APIs.
+type FileWatcher struct {
+	loader   *FileLoader
+	interval time.Duration
+	stop     chan bool
+	stopped  sync.Once
+}
+
+func NewFileWatcher(interval time.Duration, paths ...string) *FileWatcher {
+	return &FileWatcher{
+		loader:   NewFileLoader(paths...),
+		interval: interval,
+		stop:     make(chan bool),
+	}
+}
+
+//Watch blocks until Stop is called, it sends the time of each change detected to c
+func (w *FileWatcher) Watch(c chan int64) {
+	last := w.fingerprint()
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			current := w.fingerprint()
+			if current == last {
+				continue
+			}
+			last = current
+			logger.Info("config files changed")
+			select {
+			case c <- time.Now().UnixNano():
+			case <-w.stop:
+				return
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+//Stop may be called more than once
+func (w *FileWatcher) Stop() {
+	w.stopped.Do(func() {
+		close(w.stop)
+	})
+}
+
+//fingerprint sums up names, sizes and modification times of the files watched
+func (w *FileWatcher) fingerprint() string {
+	files, err := w.loader.Files()
+	if err != nil {
+		return "error:" + err.Error()
+	}
+	var builder strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			builder.WriteString(file + ":missing;")
+			continue
+		}
+		builder.WriteString(file + ":" + strconv.FormatInt(info.Size(), 10) + ":" + strconv.FormatInt(info.ModTime().UnixNano(), 10) + ";")
+	}
+	return builder.String()
+}
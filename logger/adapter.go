@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+)
+
+//StdLogger writes records through log.Logger, debug and info go to out, error goes to errOut
+type StdLogger struct {
+	dbg *log.Logger
+	inf *log.Logger
+	err *log.Logger
+}
+
+func NewStdLogger(out, errOut io.Writer) *StdLogger {
+	format := log.Ldate | log.Ltime
+	return &StdLogger{
+		dbg: log.New(out, "[DEBUG]: ", format),
+		inf: log.New(out, "[INFO]: ", format),
+		err: log.New(errOut, "[ERROR]: ", format),
+	}
+}
+
+func (l *StdLogger) Log(entry *Entry) {
+	switch entry.Level {
+	case LevelDebug:
+		l.dbg.Print(filepath.Base(entry.File) + ":" + strconv.Itoa(entry.Line) + ": " + entry.Msg + formatFields(entry.Fields))
+	case LevelInfo:
+		l.inf.Print(entry.Msg + formatFields(entry.Fields))
+	default:
+		l.err.Print(entry.File + ":" + strconv.Itoa(entry.Line) + ": " + entry.Msg + formatFields(entry.Fields))
+	}
+}
+
+//SlogLogger hands records to a slog.Logger, fields become attributes
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	return &SlogLogger{logger: logger}
+}
+
+func (l *SlogLogger) Log(entry *Entry) {
+	level := slog.LevelInfo
+	switch entry.Level {
+	case LevelDebug:
+		level = slog.LevelDebug
+	case LevelError:
+		level = slog.LevelError
+	}
+	attrs := make([]slog.Attr, 0, len(entry.Fields)+1)
+	attrs = append(attrs, slog.String("caller", entry.File+":"+strconv.Itoa(entry.Line)))
+	for _, field := range entry.Fields {
+		attrs = append(attrs, slog.Any(field.Key, field.Value))
+	}
+	l.logger.LogAttrs(context.Background(), level, entry.Msg, attrs...)
+}
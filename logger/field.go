@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+)
+
+//Field is a key value pair attached to a log record
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Template(name string) Field {
+	return Field{Key: "template", Value: name}
+}
+
+//Phone keeps only the first 3 and last 4 digits of phone
+func Phone(phone string) Field {
+	return Field{Key: "phone", Value: MaskPhone(phone)}
+}
+
+//Phones masks each phone, see Phone
+func Phones(phones []string) Field {
+	masked := make([]string, len(phones))
+	for i := range phones {
+		masked[i] = MaskPhone(phones[i])
+	}
+	return Field{Key: "phones", Value: masked}
+}
+
+func Vendor(name string) Field {
+	return Field{Key: "vendor", Value: name}
+}
+
+func MsgID(id int64) Field {
+	return Field{Key: "msg_id", Value: id}
+}
+
+//Batch is the index of the chunk a vendor request belongs to
+func Batch(index int) Field {
+	return Field{Key: "batch", Value: index}
+}
+
+func Err(err error) Field {
+	return Field{Key: "error", Value: err}
+}
+
+func MaskPhone(phone string) string {
+	if len(phone) < 7 {
+		return strings.Repeat("*", len(phone))
+	}
+	return phone[:3] + strings.Repeat("*", len(phone)-7) + phone[len(phone)-4:]
+}
+
+//formatFields renders fields as space separated key=value pairs
+func formatFields(fields []Field) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	var builder strings.Builder
+	for _, field := range fields {
+		value := fmt.Sprint(field.Value)
+		if strings.ContainsAny(value, " \t\n\"=") {
+			value = fmt.Sprintf("%q", value)
+		}
+		builder.WriteString(" " + field.Key + "=" + value)
+	}
+	return builder.String()
+}
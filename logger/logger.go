@@ -2,41 +2,127 @@ package logger
 
 import (
 	"fmt"
-	"io"
-	"io/ioutil"
-	"log"
 	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelError:
+		return "ERROR"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+//Entry is a single log record handed to the Logger
+type Entry struct {
+	Time   time.Time
+	Level  Level
+	Msg    string
+	Fields []Field
+	//file:line of the call site
+	File string
+	Line int
+}
+
+//Logger receives every log record of mane, implement it to route them into your own pipeline
+type Logger interface {
+	Log(entry *Entry)
+}
+
+type holder struct {
+	Logger
+}
+
 var (
-	dbg *log.Logger
-	inf *log.Logger
-	err *log.Logger
+	current atomic.Value
+	level   int32
 )
 
 func init() {
-	if os.Getenv("CHITU_ENV") == "production" {
-		initLogger(ioutil.Discard, os.Stdout, os.Stderr)
-	} else {
-		initLogger(os.Stdout, os.Stdout, os.Stderr)
+	//config.SetRunMode raises the level and turns masking on in production
+	SetLevel(LevelDebug)
+	SetLogger(NewStdLogger(os.Stdout, os.Stderr))
+}
+
+//SetLogger replaces the logger used by mane, it is safe to call at any time
+func SetLogger(l Logger) {
+	current.Store(holder{l})
+}
+
+//SetLevel drops records below level, it is safe to call at any time
+func SetLevel(l Level) {
+	atomic.StoreInt32(&level, int32(l))
+}
+
+func GetLevel() Level {
+	return Level(atomic.LoadInt32(&level))
+}
+
+func Enabled(l Level) bool {
+	return l >= GetLevel()
+}
+
+//output builds an entry for the call site skip frames above and hands it to the logger
+func output(skip int, l Level, msg string, fields []Field) {
+	if !Enabled(l) {
+		return
+	}
+	entry := &Entry{
+		Time:   time.Now(),
+		Level:  l,
+		Msg:    strings.TrimSuffix(msg, "\n"),
+		Fields: fields,
+	}
+	_, entry.File, entry.Line, _ = runtime.Caller(skip)
+	if MaskingEnabled() {
+		maskEntry(entry)
 	}
+	current.Load().(holder).Log(entry)
 }
 
-func initLogger(debug, info, error io.Writer) {
-	format := log.Ldate | log.Ltime
-	dbg = log.New(debug, "[DEBUG]: ", format|log.Lshortfile)
-	inf = log.New(info, "[INFO]: ", format)
-	err = log.New(error, "[ERROR]: ", format|log.Llongfile)
+func Debug(msg string, fields ...Field) {
+	output(2, LevelDebug, msg, fields)
+}
+
+func Info(msg string, fields ...Field) {
+	output(2, LevelInfo, msg, fields)
+}
+
+func Error(msg string, fields ...Field) {
+	output(2, LevelError, msg, fields)
 }
 
 func I(format string, args ...interface{}) {
-	_ = inf.Output(2, fmt.Sprintf(format, args...))
+	if Enabled(LevelInfo) {
+		output(2, LevelInfo, fmt.Sprintf(format, args...), nil)
+	}
 }
 
 func E(format string, args ...interface{}) {
-	_ = err.Output(2, fmt.Sprintf(format, args...))
+	if Enabled(LevelError) {
+		output(2, LevelError, fmt.Sprintf(format, args...), nil)
+	}
 }
 
 func D(format string, args ...interface{}) {
-	_ = dbg.Output(2, fmt.Sprintf(format, args...))
+	if Enabled(LevelDebug) {
+		output(2, LevelDebug, fmt.Sprintf(format, args...), nil)
+	}
 }
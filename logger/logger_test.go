@@ -0,0 +1,122 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type recorder struct {
+	entries []*Entry
+}
+
+func (r *recorder) Log(entry *Entry) {
+	r.entries = append(r.entries, entry)
+}
+
+func TestSetLogger(t *testing.T) {
+	defer SetLogger(current.Load().(holder).Logger)
+	defer SetLevel(GetLevel())
+
+	r := &recorder{}
+	SetLogger(r)
+	SetLevel(LevelInfo)
+	D("dropped %d", 1)
+	I("hello %s\n", "mane")
+	Error("failed", Phone("13812345678"), MsgID(42), Err(errors.New("boom")))
+	if len(r.entries) != 2 {
+		t.Fatalf("TestSetLogger failed. entries:%d\n", len(r.entries))
+	}
+	if r.entries[0].Msg != "hello mane" || r.entries[0].Level != LevelInfo {
+		t.Errorf("TestSetLogger failed. entry:%+v\n", r.entries[0])
+	}
+	if !strings.HasSuffix(r.entries[0].File, "logger_test.go") {
+		t.Errorf("TestSetLogger caller failed. file:%s\n", r.entries[0].File)
+	}
+	fields := r.entries[1].Fields
+	if fields[0].Value != "138****5678" || fields[1].Key != "msg_id" {
+		t.Errorf("TestSetLogger fields failed. fields:%v\n", fields)
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	var out, errOut bytes.Buffer
+	l := NewStdLogger(&out, &errOut)
+	l.Log(&Entry{Level: LevelInfo, Msg: "sent", Fields: []Field{Vendor("montnets"), Any("note", "a b")}})
+	l.Log(&Entry{Level: LevelError, Msg: "failed", File: "/src/x.go", Line: 3})
+	if !strings.HasSuffix(out.String(), "sent vendor=montnets note=\"a b\"\n") || !strings.HasPrefix(out.String(), "[INFO]: ") {
+		t.Errorf("TestStdLogger failed. out:%s\n", out.String())
+	}
+	if !strings.HasSuffix(errOut.String(), "/src/x.go:3: failed\n") {
+		t.Errorf("TestStdLogger failed. errOut:%s\n", errOut.String())
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	var out bytes.Buffer
+	l := NewSlogLogger(slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	l.Log(&Entry{Level: LevelDebug, Msg: "sent", Fields: []Field{Batch(2)}})
+	if !strings.Contains(out.String(), "level=DEBUG msg=sent") || !strings.Contains(out.String(), "batch=2") {
+		t.Errorf("TestSlogLogger failed. out:%s\n", out.String())
+	}
+}
+
+func TestMaskPhone(t *testing.T) {
+	for phone, expected := range map[string]string{
+		"13812345678": "138****5678",
+		"12345":       "*****",
+		"":            "",
+	} {
+		if masked := MaskPhone(phone); masked != expected {
+			t.Errorf("TestMaskPhone failed. phone:%s, masked:%s\n", phone, masked)
+		}
+	}
+}
+
+func TestMasking(t *testing.T) {
+	defer SetLogger(current.Load().(holder).Logger)
+	defer SetMasking(MaskingEnabled())
+
+	r := &recorder{}
+	SetLogger(r)
+	SetMasking(true)
+	MarkSensitive("password")
+	E("failed to send sms to %v: %v", []string{"13812345678", "+8615912345678"}, "ok")
+	Error("failed", Any("phones", []string{"13812345678"}), Any("password", "secret"),
+		Err(errors.New("bad mobile 13812345678")), Variables(map[string]string{"code": "1234", "name": "bob"}, []string{"code"}))
+	if msg := r.entries[0].Msg; strings.Contains(msg, "13812345678") || strings.Contains(msg, "15912345678") {
+		t.Errorf("TestMasking msg failed. msg:%s\n", msg)
+	}
+	fields := r.entries[1].Fields
+	if fields[0].Value.([]string)[0] != "138****5678" || fields[1].Value != "******" || fields[2].Value != "bad mobile 138****5678" {
+		t.Errorf("TestMasking fields failed. fields:%v\n", fields)
+	}
+	if variables := fields[3].Value.(map[string]string); variables["code"] != "******" || variables["name"] != "bob" {
+		t.Errorf("TestMasking variables failed. variables:%v\n", variables)
+	}
+	if err := MaskError(errors.New("13812345678 is invalid")); err.Error() != "138****5678 is invalid" {
+		t.Errorf("TestMasking MaskError failed. err:%v\n", err)
+	}
+
+	SetMasking(false)
+	I("sent to 13812345678")
+	if r.entries[2].Msg != "sent to 13812345678" {
+		t.Errorf("TestMasking disabled failed. msg:%s\n", r.entries[2].Msg)
+	}
+}
+
+func TestMask_Boundaries(t *testing.T) {
+	for s, expected := range map[string]string{
+		"msg_id=1760857857123456789":           "msg_id=1760857857123456789",
+		"at 17608578571234 amount 13800000000": "at 17608578571234 amount 138****0000",
+		"13812345678,13912345678":              "138****5678,139****5678",
+		"tel:8613812345678;":                   "tel:861******5678;",
+		"order 138123456789":                   "order 138123456789",
+	} {
+		if masked := Mask(s); masked != expected {
+			t.Errorf("TestMask_Boundaries failed. s:%s, masked:%s\n", s, masked)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"sync/atomic"
+)
+
+const masked = "******"
+
+type maskPattern struct {
+	pattern *regexp.Regexp
+	replace func(string) string
+}
+
+type maskRegistry struct {
+	locker    *sync.RWMutex
+	patterns  []maskPattern
+	sensitive map[string]bool
+}
+
+var (
+	masks   maskRegistry
+	masking int32
+)
+
+//DefaultPhonePattern matches whole runs of digits long enough to hold a mainland mobile number,
+//only runs that are one, with optional country code, are masked so msg ids, timestamps and amounts stay intact
+var DefaultPhonePattern = regexp.MustCompile(`\+?\d{11,}`)
+
+var phoneNumber = regexp.MustCompile(`^(?:\+?86)?1[3-9]\d{9}$`)
+
+//maskPhoneRun masks run if it is a phone number and leaves it untouched otherwise
+func maskPhoneRun(run string) string {
+	if !phoneNumber.MatchString(run) {
+		return run
+	}
+	return MaskPhone(run)
+}
+
+func init() {
+	masks = maskRegistry{
+		locker:    new(sync.RWMutex),
+		sensitive: make(map[string]bool),
+	}
+	AddMaskPattern(DefaultPhonePattern, maskPhoneRun)
+}
+
+//SetMasking turns masking of log output on or off, config.SetRunMode turns it on in production
+func SetMasking(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&masking, value)
+}
+
+func MaskingEnabled() bool {
+	return atomic.LoadInt32(&masking) == 1
+}
+
+//AddMaskPattern makes every match of pattern in log output be replaced by replace(match), nil replace hides it completely
+func AddMaskPattern(pattern *regexp.Regexp, replace func(string) string) {
+	if replace == nil {
+		replace = func(string) string { return masked }
+	}
+	masks.locker.Lock()
+	defer masks.locker.Unlock()
+	masks.patterns = append(masks.patterns, maskPattern{pattern: pattern, replace: replace})
+}
+
+//MarkSensitive hides values of fields and template variables with given keys completely
+func MarkSensitive(keys ...string) {
+	masks.locker.Lock()
+	defer masks.locker.Unlock()
+	for _, key := range keys {
+		masks.sensitive[key] = true
+	}
+}
+
+func isSensitive(key string) bool {
+	masks.locker.RLock()
+	defer masks.locker.RUnlock()
+	return masks.sensitive[key]
+}
+
+//Mask applies all mask patterns to s regardless of whether masking is enabled
+func Mask(s string) string {
+	masks.locker.RLock()
+	defer masks.locker.RUnlock()
+	for _, p := range masks.patterns {
+		s = p.pattern.ReplaceAllStringFunc(s, p.replace)
+	}
+	return s
+}
+
+//MaskError returns an error whose message went through Mask when masking is enabled,
+//use it for errors carrying text received from vendors.
+func MaskError(err error) error {
+	if err == nil || !MaskingEnabled() {
+		return err
+	}
+	message := Mask(err.Error())
+	if message == err.Error() {
+		return err
+	}
+	return errors.New(message)
+}
+
+//Variables renders template variables, the ones named in sensitive or marked by MarkSensitive are hidden
+func Variables(variables map[string]string, sensitive []string) Field {
+	hidden := make(map[string]bool, len(sensitive))
+	for _, key := range sensitive {
+		hidden[key] = true
+	}
+	rendered := make(map[string]string, len(variables))
+	for key, value := range variables {
+		if hidden[key] || isSensitive(key) {
+			value = masked
+		}
+		rendered[key] = value
+	}
+	return Field{Key: "variables", Value: rendered}
+}
+
+//maskEntry masks the message and fields of entry in place
+func maskEntry(entry *Entry) {
+	entry.Msg = Mask(entry.Msg)
+	if len(entry.Fields) == 0 {
+		return
+	}
+	fields := make([]Field, len(entry.Fields))
+	for i, field := range entry.Fields {
+		fields[i] = maskField(field)
+	}
+	entry.Fields = fields
+}
+
+func maskField(field Field) Field {
+	if isSensitive(field.Key) {
+		return Field{Key: field.Key, Value: masked}
+	}
+	switch value := field.Value.(type) {
+	case nil:
+		return field
+	case string:
+		return Field{Key: field.Key, Value: Mask(value)}
+	case []string:
+		maskedValues := make([]string, len(value))
+		for i := range value {
+			maskedValues[i] = Mask(value[i])
+		}
+		return Field{Key: field.Key, Value: maskedValues}
+	case map[string]string:
+		maskedValues := make(map[string]string, len(value))
+		for k, v := range value {
+			if isSensitive(k) {
+				v = masked
+			}
+			maskedValues[k] = Mask(v)
+		}
+		return Field{Key: field.Key, Value: maskedValues}
+	case error, fmt.Stringer:
+		return Field{Key: field.Key, Value: Mask(fmt.Sprint(value))}
+	default:
+		return field
+	}
+}
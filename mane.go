@@ -6,11 +6,18 @@ import (
 	"github.com/linkedin-inc/mane/vendor"
 )
 
+//InitSMS runs in the run mode set by config.SetRunMode, if none was set it is dry run, or production when CHITU_ENV is production
 func InitSMS(conf map[template.Channel]config.SMSConfig) {
 	config.Init()
 	vendor.Prepare(conf)
 }
 
+//InitSMSWithMode is InitSMS with an explicit run mode, whitelist is only used by config.ModeWhitelist
+func InitSMSWithMode(conf map[template.Channel]config.SMSConfig, mode config.RunMode, whitelist ...string) {
+	config.SetRunMode(mode, whitelist...)
+	InitSMS(conf)
+}
+
 func InitPush() {
 	//TODO
 }
@@ -0,0 +1,307 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+type metricRegistry struct {
+	locker  *sync.RWMutex
+	names   map[string]bool
+	metrics []metric
+}
+
+//initialized statically since metrics are declared as package variables
+var registry = metricRegistry{
+	locker: new(sync.RWMutex),
+	names:  make(map[string]bool),
+}
+
+func register(name string, m metric) {
+	registry.locker.Lock()
+	defer registry.locker.Unlock()
+	if registry.names[name] {
+		panic("duplicated metric registered: " + name)
+	}
+	registry.names[name] = true
+	registry.metrics = append(registry.metrics, m)
+}
+
+//Write all registered metrics to w in prometheus text exposition format
+func Write(w io.Writer) error {
+	registry.locker.RLock()
+	defer registry.locker.RUnlock()
+	buffered := bufio.NewWriter(w)
+	for _, m := range registry.metrics {
+		m.write(buffered)
+	}
+	return buffered.Flush()
+}
+
+//Handler serves all registered metrics, mount it at /metrics for prometheus to scrape
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		_ = Write(w)
+	})
+}
+
+//vec holds one series per combination of label values
+type vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	locker sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	buckets     []uint64
+	count       uint64
+}
+
+func newVec(name, help, kind string, labels []string) *vec {
+	return &vec{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: make(map[string]*series),
+	}
+}
+
+//with returns the series for labelValues, the caller must hold the lock
+func (v *vec) with(labelValues []string) *series {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, existed := v.series[key]
+	if !existed {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		v.series[key] = s
+	}
+	return s
+}
+
+//sorted returns the series ordered by label values so the output is stable, the caller must hold the lock
+func (v *vec) sorted() []*series {
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sorted := make([]*series, len(keys))
+	for i, key := range keys {
+		sorted[i] = v.series[key]
+	}
+	return sorted
+}
+
+func (v *vec) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.kind)
+}
+
+func (v *vec) writeSample(w *bufio.Writer, suffix string, labelValues []string, extraName, extraValue string, value float64) {
+	w.WriteString(v.name + suffix)
+	names := v.labels
+	values := labelValues
+	if extraName != "" {
+		names = append(append([]string(nil), names...), extraName)
+		values = append(append([]string(nil), values...), extraValue)
+	}
+	if len(names) > 0 {
+		w.WriteByte('{')
+		for i := range names {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(names[i] + `="` + escapeLabel(values[i]) + `"`)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+//Counter is a monotonically increasing value
+type Counter struct {
+	*vec
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newVec(name, help, "counter", labels)}
+	register(name, c)
+	return c
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+//Add delta to the series of labelValues, negative delta is ignored
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.with(labelValues).value += delta
+}
+
+//Value returns the current value of the series of labelValues
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	return c.with(labelValues).value
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.writeHeader(w)
+	for _, s := range c.sorted() {
+		c.writeSample(w, "", s.labelValues, "", "", s.value)
+	}
+}
+
+//Gauge is a value that can go up and down
+type Gauge struct {
+	*vec
+}
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{newVec(name, help, "gauge", labels)}
+	register(name, g)
+	return g
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.locker.Lock()
+	defer g.locker.Unlock()
+	g.with(labelValues).value = value
+}
+
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.locker.Lock()
+	defer g.locker.Unlock()
+	g.with(labelValues).value += delta
+}
+
+func (g *Gauge) Value(labelValues ...string) float64 {
+	g.locker.Lock()
+	defer g.locker.Unlock()
+	return g.with(labelValues).value
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.locker.Lock()
+	defer g.locker.Unlock()
+	g.writeHeader(w)
+	for _, s := range g.sorted() {
+		g.writeSample(w, "", s.labelValues, "", "", s.value)
+	}
+}
+
+//DefaultBuckets suit latencies of http requests in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+//Histogram counts observations in cumulative buckets
+type Histogram struct {
+	*vec
+	upperBounds []float64
+}
+
+//NewHistogram with given upper bounds in increasing order, nil means DefaultBuckets
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &Histogram{vec: newVec(name, help, "histogram", labels), upperBounds: buckets}
+	register(name, h)
+	return h
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	s := h.with(labelValues)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(h.upperBounds))
+	}
+	for i, bound := range h.upperBounds {
+		if value <= bound {
+			s.buckets[i]++
+		}
+	}
+	s.count++
+	s.value += value
+}
+
+//Count returns how many values were observed for the series of labelValues
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	return h.with(labelValues).count
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	h.writeHeader(w)
+	for _, s := range h.sorted() {
+		for i, bound := range h.upperBounds {
+			var count uint64
+			if s.buckets != nil {
+				count = s.buckets[i]
+			}
+			h.writeSample(w, "_bucket", s.labelValues, "le", formatFloat(bound), float64(count))
+		}
+		h.writeSample(w, "_bucket", s.labelValues, "le", "+Inf", float64(s.count))
+		h.writeSample(w, "_sum", s.labelValues, "", "", s.value)
+		h.writeSample(w, "_count", s.labelValues, "", "", float64(s.count))
+	}
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
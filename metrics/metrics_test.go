@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	counter := NewCounter("test_counter_total", "A counter.", "name")
+	gauge := NewGauge("test_gauge", "A gauge.")
+	histogram := NewHistogram("test_histogram_seconds", "A histogram.", []float64{0.1, 1}, "op")
+
+	counter.Inc(`quo"te`)
+	counter.Add(2, "b")
+	counter.Add(-1, "b")
+	gauge.Set(3.5)
+	histogram.Observe(0.05, "send")
+	histogram.Observe(0.5, "send")
+	histogram.Observe(5, "send")
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	for _, expected := range []string{
+		"# HELP test_counter_total A counter.\n# TYPE test_counter_total counter\n",
+		"test_counter_total{name=\"b\"} 2\ntest_counter_total{name=\"quo\\\"te\"} 1\n",
+		"# TYPE test_gauge gauge\ntest_gauge 3.5\n",
+		"test_histogram_seconds_bucket{op=\"send\",le=\"0.1\"} 1\n",
+		"test_histogram_seconds_bucket{op=\"send\",le=\"1\"} 2\n",
+		"test_histogram_seconds_bucket{op=\"send\",le=\"+Inf\"} 3\n",
+		"test_histogram_seconds_sum{op=\"send\"} 5.55\n",
+		"test_histogram_seconds_count{op=\"send\"} 3\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("TestHandler failed. missing:\n%s\nin:\n%s", expected, body)
+		}
+	}
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("TestHandler failed. content type:%s\n", contentType)
+	}
+}
+
+func TestRegisterDuplicated(t *testing.T) {
+	NewGauge("test_duplicated", "")
+	defer func() {
+		if recover() == nil {
+			t.Error("TestRegisterDuplicated failed. expect panic")
+		}
+	}()
+	NewGauge("test_duplicated", "")
+}
@@ -0,0 +1,33 @@
+package metrics
+
+//metrics of the send pipeline, exposed by Handler
+var (
+	SMSAttempted = NewCounter("mane_sms_attempted_total",
+		"Messages handed to Send or MultiXSend, including those dropped or discarded.", "template", "category", "channel", "vendor")
+	SMSAccepted = NewCounter("mane_sms_accepted_total",
+		"Messages accepted by the vendor.", "template", "category", "channel", "vendor")
+	SMSFailed = NewCounter("mane_sms_failed_total",
+		"Messages rejected by the vendor.", "template", "category", "channel", "vendor")
+	SMSDiscarded = NewCounter("mane_sms_discarded_total",
+		"Messages not sent to the vendor because of the run mode.", "template", "category", "channel", "vendor", "reason")
+	MiddlewareDropped = NewCounter("mane_middleware_dropped_total",
+		"Messages prevented by a middleware action.", "action")
+	VendorRequestDuration = NewHistogram("mane_vendor_request_duration_seconds",
+		"Latency of http requests to vendors.", nil, "vendor", "operation")
+	DeliveryStatus = NewCounter("mane_delivery_status_total",
+		"Delivery reports pulled from vendors by result.", "vendor", "result")
+	PullLag = NewHistogram("mane_pull_lag_seconds",
+		"Delay between a delivery report and the pull that fetched it.",
+		[]float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}, "vendor")
+)
+
+const (
+	ResultDelivered = "delivered"
+	ResultFailed    = "failed"
+)
+
+//reasons of SMSDiscarded
+const (
+	ReasonDryRun    = "dry_run"
+	ReasonWhitelist = "whitelist"
+)
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"fmt"
+
 	"github.com/linkedin-inc/mane/logger"
 	m "github.com/linkedin-inc/mane/model"
 )
@@ -15,10 +17,21 @@ func (*ErrorReport) Name() string {
 	return "ErrorReport"
 }
 
-func (*ErrorReport) Call(context *m.SMSContext, next func() bool) bool {
+func (e *ErrorReport) Call(context *m.SMSContext, next func() bool) bool {
+	return e.call(context, next, true)
+}
+
+//DryCall recovers like Call without logging
+func (e *ErrorReport) DryCall(context *m.SMSContext, next func() bool) bool {
+	return e.call(context, next, false)
+}
+
+func (*ErrorReport) call(context *m.SMSContext, next func() bool, log bool) bool {
 	defer func() {
 		if err := recover(); err != nil {
-			logger.E("sms middleware error report: %v\n", err)
+			if log {
+				logger.Error("recovered panic in middleware", logger.Template(context.Template), logger.Phone(context.Phone), logger.Any("panic", fmt.Sprint(err)))
+			}
 		}
 	}()
 	next()
@@ -1,8 +1,14 @@
 package middleware
 
 import (
+	ctx "context"
+	"fmt"
+	"time"
+
 	"github.com/linkedin-inc/mane/logger"
+	"github.com/linkedin-inc/mane/metrics"
 	"github.com/linkedin-inc/mane/model"
+	"github.com/linkedin-inc/mane/trace"
 )
 
 type Action interface {
@@ -34,21 +40,134 @@ func (m *Middleware) Prepend(action Action) *Middleware {
 }
 
 func (m *Middleware) Call(contexts []*model.SMSContext) []*model.SMSContext {
+	return m.CallContext(ctx.Background(), contexts)
+}
+
+//CallContext is Call with a span for each action attached to the trace carried by c
+func (m *Middleware) CallContext(c ctx.Context, contexts []*model.SMSContext) []*model.SMSContext {
+	allowedContexts, _ := m.Decide(c, contexts)
+	return allowedContexts
+}
+
+//Decide is CallContext also returning for each of contexts the decision taken.
+//An action that neither refused nor called next, e.g. because it panicked, is the one blocking the context.
+func (m *Middleware) Decide(c ctx.Context, contexts []*model.SMSContext) ([]*model.SMSContext, []*model.Decision) {
 	var allowedContexts []*model.SMSContext
-	for _, context := range contexts {
-		continuation(m.actions, context, func() {
+	decisions := make([]*model.Decision, len(contexts))
+	for i, context := range contexts {
+		t := &trail{}
+		continuation(c, m.actions, context, t, func() {
+			t.allowed = true
 			allowedContexts = append(allowedContexts, context)
 		})()
+		decisions[i] = t.decide(context)
 	}
-	return allowedContexts
+	return allowedContexts, decisions
+}
+
+//DryCaller is implemented by actions with side effects, e.g. counting sends or remembering phones,
+//DryCall decides like Call without them.
+type DryCaller interface {
+	DryCall(context *model.SMSContext, next func() bool) bool
+}
+
+type dryRunKey struct{}
+
+//WithDryRun makes CallContext decide without side effects: actions implementing DryCaller are asked through DryCall,
+//and contexts refused are neither logged nor counted.
+func WithDryRun(c ctx.Context) ctx.Context {
+	return ctx.WithValue(c, dryRunKey{}, true)
+}
+
+func isDryRun(c ctx.Context) bool {
+	return c.Value(dryRunKey{}) != nil
+}
+
+//Explainer is implemented by actions telling why they refused a context
+type Explainer interface {
+	Explain(context *model.SMSContext) string
 }
 
-func continuation(actions []Action, context *model.SMSContext, final func()) func() bool {
+//trail follows a context through the actions
+type trail struct {
+	//entered is the deepest action reached, remaining is how many actions were left when it was called
+	entered   Action
+	remaining int
+	//returned is false if entered panicked, actions recovering from it may refuse the context then
+	returned bool
+	//blocker is the first action refusing the context
+	blocker Action
+	allowed bool
+	//panicked is set once an action panicked, value is what it panicked with
+	panicked bool
+	value    interface{}
+}
+
+func (t *trail) decide(context *model.SMSContext) *model.Decision {
+	decision := &model.Decision{
+		MID:       context.ID,
+		Phone:     context.Phone,
+		Template:  context.Template,
+		Allowed:   t.allowed,
+		Timestamp: time.Now(),
+	}
+	switch {
+	case t.allowed:
+	case t.panicked && !t.returned:
+		decision.Action = t.entered.Name()
+		decision.Reason = "panic recovered by ErrorReport"
+		decision.Panic = fmt.Sprint(t.value)
+	case !t.returned:
+		decision.Action = t.entered.Name()
+		decision.Reason = "panicked"
+	case t.blocker == nil:
+		//entered let the context go without refusing it
+		decision.Action = t.entered.Name()
+		decision.Reason = "next not called"
+	default:
+		decision.Action = t.blocker.Name()
+		if explainer, ok := t.blocker.(Explainer); ok {
+			decision.Reason = explainer.Explain(context)
+		}
+	}
+	return decision
+}
+
+func continuation(c ctx.Context, actions []Action, context *model.SMSContext, t *trail, final func()) func() bool {
 	return func() (acknowledge bool) {
+		defer func() {
+			//note the deepest panic in the trail and let it go on to the action recovering from it
+			if value := recover(); value != nil {
+				if !t.panicked {
+					t.panicked, t.value = true, value
+				}
+				panic(value)
+			}
+		}()
 		if len(actions) > 0 {
-			acknowledge = actions[0].Call(context, continuation(actions[1:], context, final))
+			spanContext, span := trace.Start(c, trace.SpanMiddleware)
+			span.SetAttribute("action", actions[0].Name())
+			t.entered, t.remaining, t.returned = actions[0], len(actions), false
+			next := continuation(spanContext, actions[1:], context, t, final)
+			if dryCaller, ok := actions[0].(DryCaller); ok && isDryRun(c) {
+				acknowledge = dryCaller.DryCall(context, next)
+			} else {
+				acknowledge = actions[0].Call(context, next)
+			}
+			if t.remaining == len(actions) {
+				t.returned = true
+			}
+			span.SetAttribute("acknowledge", acknowledge)
+			span.End()
 			if !acknowledge {
-				logger.I("[p:%v, t:%v] prevented by %s\n", context.Phone, context.Template, actions[0].Name())
+				if t.blocker == nil {
+					t.blocker = actions[0]
+				}
+				if isDryRun(c) {
+					return
+				}
+				logger.Info("prevented by middleware", logger.Phone(context.Phone), logger.Template(context.Template), logger.Any("action", actions[0].Name()))
+				metrics.MiddlewareDropped.Inc(actions[0].Name())
 				return
 			}
 		} else {
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	ctx "context"
+	"sync"
 	"testing"
 
 	m "github.com/linkedin-inc/mane/model"
@@ -160,3 +162,56 @@ func TestMiddleware_Append4(t *testing.T) {
 		t.Logf("TestMiddleware_Append2 allowedContexts:%v\n", allowedContexts)
 	}
 }
+
+func (m *KeepOdd) Explain(context *m.SMSContext) string {
+	return "even phone"
+}
+
+func TestMiddleware_Decisions(t *testing.T) {
+	contexts = contexts[:0]
+	for i := 0; i < 4; i++ {
+		contexts = append(contexts, m.NewSMSContext(int64(i), u.Itoa(i), "", nil))
+	}
+	// 0 panics, 1 and 2 are refused by KeepThree, 3 passes
+	allowedContexts, decisions := NewMiddleware(NewKeepThree("KeepThree"), NewPanicZero(), NewKeepOdd("KeepOdd")).Decide(ctx.Background(), contexts)
+	if len(allowedContexts) != 1 || allowedContexts[0].Phone != "3" || len(decisions) != 4 {
+		t.Fatalf("TestMiddleware_Decisions failed. allowedContexts:%v\n", allowedContexts)
+	}
+	expected := []m.Decision{
+		{MID: 0, Phone: "0", Action: "PanicZero", Reason: "panic recovered by ErrorReport", Panic: "WTF"},
+		{MID: 1, Phone: "1", Action: "KeepThree"},
+		{MID: 2, Phone: "2", Action: "KeepThree"},
+		{MID: 3, Phone: "3", Allowed: true},
+	}
+	for i := range expected {
+		decision := *decisions[i]
+		decision.Timestamp = expected[i].Timestamp
+		if decision != expected[i] {
+			t.Fatalf("TestMiddleware_Decisions failed. decision %d:%+v\n", i, decision)
+		}
+	}
+
+	// the reason comes from the action refusing
+	_, decisions = NewMiddleware(NewKeepOdd("KeepOdd")).Decide(ctx.Background(), contexts[2:3])
+	if decisions[0].Action != "KeepOdd" || decisions[0].Reason != "even phone" {
+		t.Fatalf("TestMiddleware_Decisions failed. decision:%+v\n", decisions[0])
+	}
+}
+
+func TestMiddleware_DecideConcurrently(t *testing.T) {
+	chain := NewMiddleware(NewPanicZero())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			context := m.NewSMSContext(int64(i), u.Itoa(i%2), "", nil)
+			_, decisions := chain.Decide(ctx.Background(), []*m.SMSContext{context})
+			//only phone 0 panics, the panic of one call never shows up in another
+			if panicked := decisions[0].Panic != ""; panicked != (i%2 == 0) {
+				t.Errorf("TestMiddleware_DecideConcurrently failed. decision:%+v\n", decisions[0])
+			}
+		}(i)
+	}
+	wg.Wait()
+}
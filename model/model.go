@@ -7,6 +7,7 @@ const (
 	CollSMStatus     = "sms_status"
 	CollSMSReply     = "sms_reply"
 	CollUnsubscriber = "sms_unsubscriber"
+	CollSMSDecision  = "sms_decision"
 )
 
 type SMSState int
@@ -28,6 +29,10 @@ type SMSHistory struct {
 	Channel   int       `bson:"channel" json:"channel"`
 	Vendor    string    `bson:"vendor" json:"vendor"`
 	State     SMSState  `bson:"state" json:"state"`
+	//version of the template the content was rendered from
+	TemplateVersion int64 `bson:"template_version" json:"template_version"`
+	//variant of the template the content was rendered from
+	Variant string `bson:"variant,omitempty" json:"variant,omitempty"`
 }
 
 type DeliveryStatus struct {
@@ -38,6 +43,21 @@ type DeliveryStatus struct {
 	ErrorMsg   string    `bson:"error_msg" json:"error_msg"`
 }
 
+//Decision is what the middleware decided for a context
+type Decision struct {
+	MID      int64  `bson:"mid" json:"mid"`
+	Phone    string `bson:"phone" json:"phone"`
+	Template string `bson:"template" json:"template"`
+	Allowed  bool   `bson:"allowed" json:"allowed"`
+	//action that blocked the context
+	Action string `bson:"action,omitempty" json:"action,omitempty"`
+	//why the action blocked it, if it tells
+	Reason string `bson:"reason,omitempty" json:"reason,omitempty"`
+	//what the action panicked with, recovered by ErrorReport
+	Panic     string    `bson:"panic,omitempty" json:"panic,omitempty"`
+	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
+}
+
 type Reply struct {
 	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
 	Phone     string    `bson:"phone" json:"phone"`
@@ -49,12 +69,32 @@ type Unsubscriber struct {
 	Phone     string    `bson:"phone" json:"phone"`
 }
 
+type BalanceUnit string
+
+const (
+	//how many messages can still be sent
+	BalanceUnitMessage BalanceUnit = "message"
+	BalanceUnitCNY     BalanceUnit = "CNY"
+)
+
+type Balance struct {
+	Amount    float64     `bson:"amount" json:"amount"`
+	Unit      BalanceUnit `bson:"unit" json:"unit"`
+	Timestamp time.Time   `bson:"timestamp" json:"timestamp"`
+}
+
 type SMSContext struct {
 	ID        int64             `json:"id"`
 	Phone     string            `json:"phone"`
 	Template  string            `json:"template"`
 	Variables map[string]string `json:"variables"`
 	History   *SMSHistory       `json:"sms_history,omitempty"`
+	//pins the send to a version of the template, 0 means the active one
+	TemplateVersion int64 `json:"template_version,omitempty"`
+	//user clicks on tracked links are attributed to
+	UserID int64 `json:"user_id,omitempty"`
+	//what the middleware decided, set by Send and MultiXSend
+	Decision *Decision `json:"decision,omitempty"`
 }
 
 func NewSMSContext(id int64, phone string, template string, variables map[string]string) *SMSContext {
@@ -0,0 +1,80 @@
+package recipient
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+
+	m "github.com/linkedin-inc/mane/model"
+)
+
+//CSVReader reads recipients from CSV with a header row. The phone column is required,
+//id and user_id are optional and may be left empty, every other column is a template variable named by its header.
+//Rows are counted from 1 after the header.
+type CSVReader struct {
+	reader   *csv.Reader
+	template string
+	mapping  Mapping
+	header   []string
+	//headerErr keeps failing every read once the header is unusable
+	headerErr error
+	row       int64
+}
+
+func NewCSVReader(r io.Reader, template string) *CSVReader {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	return &CSVReader{reader: reader, template: template, mapping: Mapping{}.withDefaults()}
+}
+
+//Map changes which columns hold what, call it before reading
+func (r *CSVReader) Map(mapping Mapping) *CSVReader {
+	r.mapping = mapping.withDefaults()
+	return r
+}
+
+func (r *CSVReader) Next() (*m.SMSContext, error) {
+	if r.header == nil && r.headerErr == nil {
+		r.headerErr = r.readHeader()
+	}
+	if r.headerErr != nil {
+		return nil, r.headerErr
+	}
+	record, err := r.reader.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		r.row++
+		return nil, &RowError{Row: r.row, Err: ErrMalformedRow}
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.row++
+	if len(record) != len(r.header) {
+		return nil, &RowError{Row: r.row, Err: ErrMalformedRow}
+	}
+	row := make(map[string]string, len(record))
+	for i, value := range record {
+		row[r.header[i]] = strings.TrimSpace(value)
+	}
+	return build(row, r.row, r.mapping, r.template)
+}
+
+func (r *CSVReader) readHeader() error {
+	header, err := r.reader.Read()
+	if err != nil {
+		return err
+	}
+	found := false
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+		found = found || header[i] == r.mapping.Phone
+	}
+	if !found {
+		return ErrMissingPhoneColumn
+	}
+	r.header = header
+	return nil
+}
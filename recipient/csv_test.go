@@ -0,0 +1,65 @@
+package recipient
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCSVReader(t *testing.T) {
+	reader := NewCSVReader(strings.NewReader("name, phone,user_id\nLi,13800000000,7\n\"Wang, Jr\",13800000001,\n"), "welcome")
+	first, err := reader.Next()
+	if err != nil || first.ID != 1 || first.Phone != "13800000000" || first.UserID != 7 || first.Variables["name"] != "Li" || first.Template != "welcome" {
+		t.Fatalf("TestCSVReader failed. first:%+v, err:%v\n", first, err)
+	}
+	second, err := reader.Next()
+	if err != nil || second.ID != 2 || second.UserID != 0 || second.Variables["name"] != "Wang, Jr" {
+		t.Fatalf("TestCSVReader failed. second:%+v, err:%v\n", second, err)
+	}
+	if _, err = reader.Next(); err != io.EOF {
+		t.Fatalf("TestCSVReader failed. err:%v\n", err)
+	}
+	if _, err = NewCSVReader(strings.NewReader("name\nLi\n"), "welcome").Next(); err != ErrMissingPhoneColumn {
+		t.Fatalf("TestCSVReader failed. err:%v\n", err)
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	csvText := "mobile,nick,extra\n13800000000,Li,x\n12345,Wang,y\n13800000000,Zhao,z\n13800000001,\"bad\n"
+	reader := NewCSVReader(strings.NewReader(csvText), "welcome").Map(Mapping{Phone: "mobile", Variables: map[string]string{"nick": "name"}})
+	contexts, report, err := ReadAll(reader)
+	if err != nil || len(contexts) != 1 || contexts[0].Variables["name"] != "Li" || len(contexts[0].Variables) != 1 {
+		t.Fatalf("TestReadAll failed. contexts:%v, err:%v\n", contexts, err)
+	}
+	if report.Total != 4 || report.Valid != 1 || len(report.Errors) != 3 {
+		t.Fatalf("TestReadAll failed. report:%+v\n", report)
+	}
+	expected := []error{ErrInvalidPhone, ErrDuplicatedPhone, ErrMalformedRow}
+	for i, rowErr := range report.Errors {
+		if rowErr.Row != int64(i+2) || !errors.Is(rowErr, expected[i]) {
+			t.Fatalf("TestReadAll failed. error %d:%v\n", i, rowErr)
+		}
+	}
+
+	jsonlText := "{\"phone\":\"13800000002\",\"id\":9,\"points\":12.5,\"vip\":true}\n\n{\"phone\":\"13800000003\",\"tags\":[1]}\nnot json\n{\"id\":\"x\"}\n"
+	contexts, report, err = ReadAll(NewJSONLReader(strings.NewReader(jsonlText), "welcome"))
+	if err != nil || len(contexts) != 1 || contexts[0].ID != 9 || contexts[0].Variables["points"] != "12.5" || contexts[0].Variables["vip"] != "true" {
+		t.Fatalf("TestReadAll failed. contexts:%v, err:%v\n", contexts, err)
+	}
+	expected = []error{ErrMalformedRow, ErrMalformedRow, ErrMissingPhone}
+	if report.Total != 4 || len(report.Errors) != 3 {
+		t.Fatalf("TestReadAll failed. report:%+v\n", report)
+	}
+	for i, rowErr := range report.Errors {
+		if rowErr.Row != int64(i+2) || !errors.Is(rowErr, expected[i]) {
+			t.Fatalf("TestReadAll failed. error %d:%v\n", i, rowErr)
+		}
+	}
+
+	//mapped variables must be present in every row
+	_, report, _ = ReadAll(NewJSONLReader(strings.NewReader("{\"phone\":\"13800000002\"}\n"), "welcome").Map(Mapping{Variables: map[string]string{"nick": "name"}}))
+	if len(report.Errors) != 1 || !errors.Is(report.Errors[0], ErrMissingVariable) {
+		t.Fatalf("TestReadAll failed. report:%+v\n", report)
+	}
+}
@@ -0,0 +1,128 @@
+package recipient
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	m "github.com/linkedin-inc/mane/model"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+)
+
+var (
+	historyHeader = []string{"mid", "msg_id", "timestamp", "phone", "content", "template", "template_version", "variant", "category", "channel", "vendor", "state"}
+	statusHeader  = []string{"msg_id", "timestamp", "phone", "status_code", "error_msg"}
+)
+
+//encoder writes rows, CSV ones under a header written before the first row, JSONL ones as the json of value
+type encoder interface {
+	encode(record []string, value interface{}) error
+	Flush() error
+}
+
+func newEncoder(w io.Writer, format Format, header []string) (encoder, error) {
+	switch format {
+	case FormatCSV:
+		return &csvEncoder{writer: csv.NewWriter(w), header: header}, nil
+	case FormatJSONL:
+		return &jsonlEncoder{encoder: json.NewEncoder(w)}, nil
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+type csvEncoder struct {
+	writer *csv.Writer
+	header []string
+}
+
+func (e *csvEncoder) encode(record []string, _ interface{}) error {
+	if e.header != nil {
+		if err := e.writer.Write(e.header); err != nil {
+			return err
+		}
+		e.header = nil
+	}
+	return e.writer.Write(record)
+}
+
+func (e *csvEncoder) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type jsonlEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *jsonlEncoder) encode(_ []string, value interface{}) error {
+	return e.encoder.Encode(value)
+}
+
+func (e *jsonlEncoder) Flush() error {
+	return nil
+}
+
+//HistoryWriter exports the histories of a campaign, call Flush once done
+type HistoryWriter struct {
+	encoder
+}
+
+func NewHistoryWriter(w io.Writer, format Format) (*HistoryWriter, error) {
+	e, err := newEncoder(w, format, historyHeader)
+	if err != nil {
+		return nil, err
+	}
+	return &HistoryWriter{e}, nil
+}
+
+func (w *HistoryWriter) Write(history *m.SMSHistory) error {
+	return w.encode([]string{
+		strconv.FormatInt(history.MID, 10),
+		strconv.FormatInt(history.MsgID, 10),
+		history.Timestamp.Format(time.RFC3339),
+		history.Phone,
+		history.Content,
+		history.Template,
+		strconv.FormatInt(history.TemplateVersion, 10),
+		history.Variant,
+		history.Category,
+		strconv.Itoa(history.Channel),
+		history.Vendor,
+		strconv.Itoa(int(history.State)),
+	}, history)
+}
+
+//StatusWriter exports the delivery reports of a campaign, call Flush once done
+type StatusWriter struct {
+	encoder
+}
+
+func NewStatusWriter(w io.Writer, format Format) (*StatusWriter, error) {
+	e, err := newEncoder(w, format, statusHeader)
+	if err != nil {
+		return nil, err
+	}
+	return &StatusWriter{e}, nil
+}
+
+func (w *StatusWriter) Write(status *m.DeliveryStatus) error {
+	return w.encode([]string{
+		strconv.FormatInt(status.MsgID, 10),
+		status.Timestamp.Format(time.RFC3339),
+		status.Phone,
+		strconv.Itoa(int(status.StatusCode)),
+		status.ErrorMsg,
+	}, status)
+}
@@ -0,0 +1,44 @@
+package recipient
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	m "github.com/linkedin-inc/mane/model"
+)
+
+func TestHistoryWriter(t *testing.T) {
+	history := &m.SMSHistory{MID: 1, MsgID: 2, Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Phone: "13800000000",
+		Content: "hi, Li", Template: "welcome", TemplateVersion: 3, Category: "notice", Channel: 2, Vendor: "montnets", State: m.SMSStateUnchecked}
+	var buffer bytes.Buffer
+	writer, err := NewHistoryWriter(&buffer, FormatCSV)
+	if err != nil {
+		t.Fatalf("TestHistoryWriter failed. err:%v\n", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err = writer.Write(history); err != nil {
+			t.Fatalf("TestHistoryWriter failed. err:%v\n", err)
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		t.Fatalf("TestHistoryWriter failed. err:%v\n", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 3 || lines[0] != strings.Join(historyHeader, ",") ||
+		lines[1] != `1,2,2026-01-02T03:04:05Z,13800000000,"hi, Li",welcome,3,,notice,2,montnets,2` {
+		t.Fatalf("TestHistoryWriter failed. csv:%s\n", buffer.String())
+	}
+
+	buffer.Reset()
+	statuses, _ := NewStatusWriter(&buffer, FormatJSONL)
+	_ = statuses.Write(&m.DeliveryStatus{MsgID: 2, Timestamp: history.Timestamp, Phone: "13800000000", StatusCode: 0})
+	_ = statuses.Flush()
+	if buffer.String() != `{"msg_id":2,"timestamp":"2026-01-02T03:04:05Z","phone":"13800000000","status_code":0,"error_msg":""}`+"\n" {
+		t.Fatalf("TestHistoryWriter failed. jsonl:%s\n", buffer.String())
+	}
+	if _, err = NewStatusWriter(&buffer, Format("xml")); err != ErrUnsupportedFormat {
+		t.Fatalf("TestHistoryWriter failed. err:%v\n", err)
+	}
+}
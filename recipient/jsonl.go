@@ -0,0 +1,67 @@
+package recipient
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	m "github.com/linkedin-inc/mane/model"
+)
+
+//maxLineSize bounds a JSONL line, long enough for any sane recipient
+const maxLineSize = 1 << 20
+
+//JSONLReader reads recipients from JSON lines, one flat object per line keyed like the columns of CSVReader.
+//Numbers and booleans are taken as written, blank lines are skipped and not counted as rows.
+type JSONLReader struct {
+	scanner  *bufio.Scanner
+	template string
+	mapping  Mapping
+	row      int64
+}
+
+func NewJSONLReader(r io.Reader, template string) *JSONLReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return &JSONLReader{scanner: scanner, template: template, mapping: Mapping{}.withDefaults()}
+}
+
+//Map changes which keys hold what, call it before reading
+func (r *JSONLReader) Map(mapping Mapping) *JSONLReader {
+	r.mapping = mapping.withDefaults()
+	return r
+}
+
+func (r *JSONLReader) Next() (*m.SMSContext, error) {
+	var line []byte
+	for len(line) == 0 {
+		if !r.scanner.Scan() {
+			if err := r.scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+		line = bytes.TrimSpace(r.scanner.Bytes())
+	}
+	r.row++
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, &RowError{Row: r.row, Err: fmt.Errorf("%w, %v", ErrMalformedRow, err)}
+	}
+	row := make(map[string]string, len(object))
+	for key, value := range object {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, &RowError{Row: r.row, Err: fmt.Errorf("%w, %s is not flat", ErrMalformedRow, key)}
+		case nil:
+			row[key] = ""
+		default:
+			row[key] = fmt.Sprint(value)
+		}
+	}
+	return build(row, r.row, r.mapping, r.template)
+}
@@ -0,0 +1,150 @@
+package recipient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+
+	m "github.com/linkedin-inc/mane/model"
+)
+
+const (
+	columnPhone  = "phone"
+	columnID     = "id"
+	columnUserID = "user_id"
+)
+
+var (
+	ErrMissingPhoneColumn = errors.New("missing phone column")
+	ErrMissingPhone       = errors.New("missing phone")
+	ErrInvalidPhone       = errors.New("invalid phone")
+	ErrDuplicatedPhone    = errors.New("duplicated phone")
+	ErrMalformedID        = errors.New("malformed id")
+	ErrMissingVariable    = errors.New("missing variable")
+	ErrMalformedRow       = errors.New("malformed row")
+)
+
+//phonePattern accepts mainland numbers as well as international ones with a leading +
+var phonePattern = regexp.MustCompile(`^(?:1[3-9]\d{9}|\+\d{6,15})$`)
+
+//Source streams the recipients of a campaign, Next returns io.EOF after the last one.
+//A source must yield recipients in the same order every time it is read, campaigns resume by position.
+//A bad row is reported as a *RowError, the source can still be read past it.
+type Source interface {
+	Next() (*m.SMSContext, error)
+}
+
+//Mapping tells which columns hold what, columns are named by the CSV header or the JSONL keys
+type Mapping struct {
+	//Phone, ID and UserID name their columns, empty means phone, id and user_id
+	Phone  string
+	ID     string
+	UserID string
+	//Variables maps columns to template variables, nil means every other column is a variable of the same name
+	Variables map[string]string
+}
+
+func (mapping Mapping) withDefaults() Mapping {
+	if mapping.Phone == "" {
+		mapping.Phone = columnPhone
+	}
+	if mapping.ID == "" {
+		mapping.ID = columnID
+	}
+	if mapping.UserID == "" {
+		mapping.UserID = columnUserID
+	}
+	return mapping
+}
+
+//RowError is a row that could not be turned into a recipient, Row counts from 1
+type RowError struct {
+	Row int64
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+//Report is the outcome of validating a whole recipient list
+type Report struct {
+	Total  int
+	Valid  int
+	Errors []*RowError
+}
+
+//ReadAll reads every recipient of source, bad rows and repeated phones are left out and reported.
+//It fails only if the source can not be read at all.
+func ReadAll(source Source) ([]*m.SMSContext, *Report, error) {
+	var contexts []*m.SMSContext
+	report := &Report{}
+	seen := make(map[string]int64)
+	for {
+		context, err := source.Next()
+		if err == io.EOF {
+			return contexts, report, nil
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			report.Total++
+			report.Errors = append(report.Errors, rowErr)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		report.Total++
+		if first, existed := seen[context.Phone]; existed {
+			report.Errors = append(report.Errors, &RowError{Row: int64(report.Total), Err: fmt.Errorf("%w %s, first in row %d", ErrDuplicatedPhone, context.Phone, first)})
+			continue
+		}
+		seen[context.Phone] = int64(report.Total)
+		report.Valid++
+		contexts = append(contexts, context)
+	}
+}
+
+//build turns a row into a recipient of template, rows without id get their row number
+func build(row map[string]string, number int64, mapping Mapping, template string) (*m.SMSContext, error) {
+	context := m.NewSMSContext(number, row[mapping.Phone], template, make(map[string]string, len(row)))
+	if context.Phone == "" {
+		return nil, &RowError{Row: number, Err: ErrMissingPhone}
+	}
+	if !phonePattern.MatchString(context.Phone) {
+		return nil, &RowError{Row: number, Err: fmt.Errorf("%w %s", ErrInvalidPhone, context.Phone)}
+	}
+	var err error
+	if value := row[mapping.ID]; value != "" {
+		if context.ID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, &RowError{Row: number, Err: fmt.Errorf("%w %s", ErrMalformedID, value)}
+		}
+	}
+	if value := row[mapping.UserID]; value != "" {
+		if context.UserID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, &RowError{Row: number, Err: fmt.Errorf("%w %s", ErrMalformedID, value)}
+		}
+	}
+	if mapping.Variables == nil {
+		for column, value := range row {
+			if column != mapping.Phone && column != mapping.ID && column != mapping.UserID {
+				context.Variables[column] = value
+			}
+		}
+		return context, nil
+	}
+	for column, variable := range mapping.Variables {
+		value, existed := row[column]
+		if !existed {
+			return nil, &RowError{Row: number, Err: fmt.Errorf("%w %s", ErrMissingVariable, column)}
+		}
+		context.Variables[variable] = value
+	}
+	return context, nil
+}
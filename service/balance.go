@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/linkedin-inc/mane/logger"
+	m "github.com/linkedin-inc/mane/model"
+	t "github.com/linkedin-inc/mane/template"
+	v "github.com/linkedin-inc/mane/vendor"
+)
+
+var ErrBalanceReserved = errors.New("balance reserved for production")
+
+type BalanceThreshold struct {
+	//alerts are raised once balance drops below Alert
+	Alert float64
+	//marketing sends are blocked while balance is below Reserve, 0 means never block
+	Reserve float64
+}
+
+//BalanceAlert is called when the balance of a vendor account drops below its alert threshold
+type BalanceAlert func(vendor v.Vendor, balance *m.Balance, threshold BalanceThreshold)
+
+//BalanceWatcher periodically queries the balance of all registered vendors,
+//balances, thresholds and alerts are per vendor account, keyed by v.QuotaKey like the quotas of the shared pool.
+type BalanceWatcher struct {
+	interval   time.Duration
+	threshold  BalanceThreshold
+	thresholds map[string]BalanceThreshold
+	alerts     []BalanceAlert
+
+	locker   sync.RWMutex
+	balances map[string]*m.Balance
+	alerted  map[string]bool
+	stop     chan bool
+	stopped  sync.Once
+}
+
+//NewBalanceWatcher creates a watcher checking every interval, threshold applies to vendors without their own.
+func NewBalanceWatcher(interval time.Duration, threshold BalanceThreshold) *BalanceWatcher {
+	return &BalanceWatcher{
+		interval:   interval,
+		threshold:  threshold,
+		thresholds: make(map[string]BalanceThreshold),
+		balances:   make(map[string]*m.Balance),
+		alerted:    make(map[string]bool),
+		stop:       make(chan bool),
+	}
+}
+
+//SetThreshold overrides the threshold of the vendor account with given key, see v.QuotaKey, call it before Start
+func (w *BalanceWatcher) SetThreshold(key string, threshold BalanceThreshold) *BalanceWatcher {
+	w.thresholds[key] = threshold
+	return w
+}
+
+//OnAlert adds an alert hook, call it before Start
+func (w *BalanceWatcher) OnAlert(alert BalanceAlert) *BalanceWatcher {
+	w.alerts = append(w.alerts, alert)
+	return w
+}
+
+func (w *BalanceWatcher) thresholdOf(key string) BalanceThreshold {
+	if threshold, existed := w.thresholds[key]; existed {
+		return threshold
+	}
+	return w.threshold
+}
+
+//Start checks once right away and then every interval until Stop is called
+func (w *BalanceWatcher) Start() {
+	w.Check()
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.Check()
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+//Stop may be called more than once, a watcher stopped before Start only checks once
+func (w *BalanceWatcher) Stop() {
+	w.stopped.Do(func() {
+		close(w.stop)
+	})
+}
+
+//Check queries every registered vendor account once and raises alerts for those below their threshold.
+//Balance queries go through the balance circuit of a vendor's Breaker, a failing balance endpoint never blocks sending.
+//An alert is raised once per drop, it is raised again only after the balance went back above the threshold.
+func (w *BalanceWatcher) Check() {
+	checked := make(map[string]bool)
+	for _, vendor := range v.All() {
+		//vendors registered for several channels may share an account
+		key := v.QuotaKeyOf(vendor)
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+		balance, err := vendor.GetBalance()
+		if err == v.ErrNotImplemented {
+			continue
+		}
+		if err != nil {
+			logger.Error("failed to check balance", logger.Vendor(string(vendor.Name())), logger.Err(err))
+			continue
+		}
+		threshold := w.thresholdOf(key)
+		below := balance.Amount < threshold.Alert
+		w.locker.Lock()
+		w.balances[key] = balance
+		alert := below && !w.alerted[key]
+		w.alerted[key] = below
+		w.locker.Unlock()
+		if alert {
+			logger.Error("balance is low", logger.Vendor(string(vendor.Name())), logger.Any("account", key),
+				logger.Any("amount", balance.Amount), logger.Any("unit", balance.Unit))
+			for _, hook := range w.alerts {
+				hook(vendor, balance, threshold)
+			}
+		}
+	}
+}
+
+//Balance returns the last known balance of the account of vendor, nil if it was never checked
+func (w *BalanceWatcher) Balance(vendor v.Vendor) *m.Balance {
+	w.locker.RLock()
+	defer w.locker.RUnlock()
+	return w.balances[v.QuotaKeyOf(vendor)]
+}
+
+//Allowed tells whether vendor may be used to send on channel, only marketing is ever blocked
+func (w *BalanceWatcher) Allowed(vendor v.Vendor, channel t.Channel) bool {
+	if channel != t.MarketingChannel {
+		return true
+	}
+	balance := w.Balance(vendor)
+	if balance == nil {
+		return true
+	}
+	return balance.Amount >= w.thresholdOf(v.QuotaKeyOf(vendor)).Reserve
+}
+
+var balanceWatcher *BalanceWatcher
+
+//RegisterBalanceWatcher makes Send and MultiXSend consult watcher before sending marketing messages
+func RegisterBalanceWatcher(watcher *BalanceWatcher) {
+	balanceWatcher = watcher
+}
+
+func checkBalance(vendor v.Vendor, channel t.Channel) error {
+	if balanceWatcher == nil || balanceWatcher.Allowed(vendor, channel) {
+		return nil
+	}
+	return ErrBalanceReserved
+}
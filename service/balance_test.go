@@ -0,0 +1,142 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	m "github.com/linkedin-inc/mane/model"
+	tp "github.com/linkedin-inc/mane/template"
+	v "github.com/linkedin-inc/mane/vendor"
+)
+
+type balanceVendor struct {
+	balance float64
+}
+
+func (b *balanceVendor) Name() v.Name {
+	return "balance"
+}
+
+func (b *balanceVendor) Send(contexts []*m.SMSContext) ([]*m.SMSContext, error) {
+	return contexts, nil
+}
+
+func (b *balanceVendor) MultiXSend(contexts []*m.SMSContext) ([]*m.SMSContext, error) {
+	return contexts, nil
+}
+
+func (b *balanceVendor) Status() ([]*m.DeliveryStatus, error) {
+	return nil, nil
+}
+
+func (b *balanceVendor) Reply() ([]*m.Reply, error) {
+	return nil, nil
+}
+
+func (b *balanceVendor) GetBalance() (*m.Balance, error) {
+	return &m.Balance{Amount: b.balance, Unit: m.BalanceUnitMessage, Timestamp: time.Now()}, nil
+}
+
+func TestBalanceWatcher(t *testing.T) {
+	stub := &balanceVendor{balance: 1000}
+	v.Register(tp.MarketingChannel, stub)
+	vendor, err := v.GetByChannel(tp.MarketingChannel)
+	if err != nil {
+		t.Fatalf("TestBalanceWatcher failed. err:%v\n", err)
+	}
+
+	alerts := 0
+	watcher := NewBalanceWatcher(time.Hour, BalanceThreshold{Alert: 500, Reserve: 100}).
+		OnAlert(func(vendor v.Vendor, balance *m.Balance, threshold BalanceThreshold) {
+			alerts++
+		})
+	watcher.Check()
+	if alerts != 0 || watcher.Balance(vendor).Amount != 1000 {
+		t.Fatalf("TestBalanceWatcher failed. alerts:%d, balance:%v\n", alerts, watcher.Balance(vendor))
+	}
+
+	stub.balance = 50
+	watcher.Check()
+	watcher.Check()
+	if alerts != 1 {
+		t.Errorf("TestBalanceWatcher alert failed. alerts:%d\n", alerts)
+	}
+	if watcher.Allowed(vendor, tp.MarketingChannel) || !watcher.Allowed(vendor, tp.ProductionChannel) {
+		t.Error("TestBalanceWatcher reserve failed")
+	}
+
+	RegisterBalanceWatcher(watcher)
+	defer RegisterBalanceWatcher(nil)
+	if err := checkBalance(vendor, tp.MarketingChannel); err != ErrBalanceReserved {
+		t.Errorf("TestBalanceWatcher checkBalance failed. err:%v\n", err)
+	}
+
+	stub.balance = 600
+	watcher.Check()
+	stub.balance = 400
+	watcher.Check()
+	if alerts != 2 || !watcher.Allowed(vendor, tp.MarketingChannel) {
+		t.Errorf("TestBalanceWatcher rearm failed. alerts:%d\n", alerts)
+	}
+}
+
+type brokenBalanceVendor struct {
+	balanceVendor
+}
+
+func (b *brokenBalanceVendor) Name() v.Name {
+	return "broken_balance"
+}
+
+func (b *brokenBalanceVendor) GetBalance() (*m.Balance, error) {
+	return nil, v.ErrQueryBalanceFailed
+}
+
+func TestBalanceWatcher_Stop(t *testing.T) {
+	watcher := NewBalanceWatcher(time.Hour, BalanceThreshold{})
+	watcher.Stop()
+	watcher.Stop()
+
+	// a broken balance endpoint keeps the vendor available for sending
+	breaker := v.NewBreaker(&brokenBalanceVendor{}, 1, time.Hour)
+	// registered on a channel no template uses
+	v.Register(tp.UnknownChannel, breaker)
+	watcher = NewBalanceWatcher(time.Hour, BalanceThreshold{})
+	watcher.Start()
+	watcher.Stop()
+	watcher.Stop()
+	if breaker.StateOf(v.OpBalance) != v.StateOpen || !breaker.Available() {
+		t.Fatalf("TestBalanceWatcher_Stop failed. balance:%v, send:%v\n", breaker.StateOf(v.OpBalance), breaker.State())
+	}
+}
+
+type accountVendor struct {
+	balanceVendor
+	account string
+}
+
+func (a *accountVendor) QuotaKey() string {
+	return v.QuotaKey(a.Name(), a.account)
+}
+
+func TestBalanceWatcher_Accounts(t *testing.T) {
+	low, other := &accountVendor{balanceVendor{balance: 50}, "low"}, &accountVendor{balanceVendor{balance: 50}, "other"}
+	// registered on a channel no template uses, low twice as if it served two channels
+	v.Register(tp.UnknownChannel, low)
+	v.Register(tp.UnknownChannel, &accountVendor{balanceVendor{balance: 50}, "low"})
+	v.Register(tp.UnknownChannel, other)
+
+	alerted := make(map[string]int)
+	watcher := NewBalanceWatcher(time.Hour, BalanceThreshold{}).
+		SetThreshold(v.QuotaKey("balance", "low"), BalanceThreshold{Alert: 100, Reserve: 100}).
+		OnAlert(func(vendor v.Vendor, balance *m.Balance, threshold BalanceThreshold) {
+			alerted[v.QuotaKeyOf(vendor)]++
+		})
+	watcher.Check()
+	if len(alerted) != 1 || alerted[v.QuotaKey("balance", "low")] != 1 {
+		t.Fatalf("TestBalanceWatcher_Accounts failed. alerted:%v\n", alerted)
+	}
+	if watcher.Allowed(low, tp.MarketingChannel) || !watcher.Allowed(other, tp.MarketingChannel) {
+		t.Fatal("TestBalanceWatcher_Accounts reserve failed")
+	}
+}
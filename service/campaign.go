@@ -0,0 +1,379 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/linkedin-inc/mane/logger"
+	m "github.com/linkedin-inc/mane/model"
+	"github.com/linkedin-inc/mane/recipient"
+)
+
+const defaultCampaignChunkSize = 500
+
+var (
+	ErrCampaignCancelled = errors.New("campaign cancelled")
+	ErrCampaignStarted   = errors.New("campaign already started")
+)
+
+type CampaignState int
+
+const (
+	CampaignPending CampaignState = iota
+	CampaignRunning
+	CampaignPaused
+	CampaignCancelled
+	CampaignDone
+	//CampaignInterrupted is left by a Run stopped by its ctx or a source failure, a new campaign resumes from the checkpoint
+	CampaignInterrupted
+)
+
+func (s CampaignState) String() string {
+	switch s {
+	case CampaignPending:
+		return "pending"
+	case CampaignRunning:
+		return "running"
+	case CampaignPaused:
+		return "paused"
+	case CampaignCancelled:
+		return "cancelled"
+	case CampaignDone:
+		return "done"
+	case CampaignInterrupted:
+		return "interrupted"
+	default:
+		return "unknown"
+	}
+}
+
+//CampaignProgress counts recipients handled so far, including the ones handled before a resume
+type CampaignProgress struct {
+	Campaign string
+	State    CampaignState
+	Offset   int64
+	Sent     int64
+	Failed   int64
+}
+
+type CampaignOptions struct {
+	//ChunkSize is how many recipients are sent by one MultiXSend, 0 means 500, it is capped to Rate
+	//so that no more than a second worth of recipients goes out at once
+	ChunkSize int
+	//Rate is how many recipients are sent per second at most, 0 means unlimited
+	Rate int
+	//Checkpoints saves progress after every chunk and resumes from it, nil means never resume
+	Checkpoints CheckpointStore
+	//OnProgress is called after every chunk
+	OnProgress func(progress CampaignProgress)
+	//OnSent is called with the recipients of every chunk accepted by the vendor, e.g. to export their histories
+	OnSent func(succeed []*m.SMSContext)
+}
+
+//Campaign sends to a stream of recipients too large for one call, in chunks at a target rate.
+//Recipients of a chunk in flight when the process dies are sent again on resume, bad rows of the source count as failed.
+type Campaign struct {
+	id      string
+	source  recipient.Source
+	options CampaignOptions
+	send    func(ctx context.Context, contexts []*m.SMSContext) ([]*m.SMSContext, error)
+
+	locker   sync.Mutex
+	progress CampaignProgress
+	resumed  chan struct{}
+	cancel   chan struct{}
+}
+
+//NewCampaign sends to the recipients of source, id names the checkpoint so it must be stable across restarts
+func NewCampaign(id string, source recipient.Source, options CampaignOptions) *Campaign {
+	if options.ChunkSize <= 0 {
+		options.ChunkSize = defaultCampaignChunkSize
+	}
+	if options.Rate > 0 && options.ChunkSize > options.Rate {
+		options.ChunkSize = options.Rate
+	}
+	return &Campaign{
+		id:       id,
+		source:   source,
+		options:  options,
+		send:     MultiXSendContext,
+		progress: CampaignProgress{Campaign: id},
+		cancel:   make(chan struct{}),
+	}
+}
+
+//Run sends until the source is exhausted, it returns ErrCampaignCancelled after Cancel and ctx.Err() once ctx is done.
+//A campaign done according to its checkpoint returns right away, one stopped early saves a last checkpoint.
+func (c *Campaign) Run(ctx context.Context) error {
+	if err := c.start(); err != nil {
+		return err
+	}
+	if err := c.run(ctx); err != nil {
+		c.interrupt()
+		return err
+	}
+	return nil
+}
+
+func (c *Campaign) run(ctx context.Context) error {
+	if err := c.restore(); err != nil {
+		return err
+	}
+	if c.Progress().State == CampaignDone {
+		return nil
+	}
+	if err := c.skip(); err != nil {
+		return err
+	}
+	begin, base := time.Now(), c.Progress().Offset
+	for {
+		if err := c.wait(ctx); err != nil {
+			return err
+		}
+		chunk, ends, rows, err := c.read()
+		if rows > 0 {
+			c.deliver(ctx, chunk, ends, rows)
+			if err := c.pace(ctx, begin, c.Progress().Offset-base); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			c.finish(CampaignDone)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+//Pause stops the campaign after the chunk in flight
+func (c *Campaign) Pause() {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	if c.progress.State == CampaignRunning {
+		c.progress.State = CampaignPaused
+		c.resumed = make(chan struct{})
+	}
+}
+
+func (c *Campaign) Resume() {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	if c.progress.State == CampaignPaused {
+		c.progress.State = CampaignRunning
+		close(c.resumed)
+	}
+}
+
+//Cancel stops the campaign for good after the chunk in flight, its checkpoint is kept
+func (c *Campaign) Cancel() {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	if c.progress.State != CampaignCancelled && c.progress.State != CampaignDone {
+		c.progress.State = CampaignCancelled
+		close(c.cancel)
+	}
+}
+
+func (c *Campaign) Progress() CampaignProgress {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	return c.progress
+}
+
+func (c *Campaign) start() error {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	switch c.progress.State {
+	case CampaignPending:
+		c.progress.State = CampaignRunning
+		return nil
+	case CampaignCancelled:
+		return ErrCampaignCancelled
+	default:
+		return ErrCampaignStarted
+	}
+}
+
+func (c *Campaign) restore() error {
+	if c.options.Checkpoints == nil {
+		return nil
+	}
+	checkpoint, err := c.options.Checkpoints.LoadCheckpoint(c.id)
+	if err == ErrCheckpointNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.progress.Offset, c.progress.Sent, c.progress.Failed = checkpoint.Offset, checkpoint.Sent, checkpoint.Failed
+	if checkpoint.Done {
+		c.progress.State = CampaignDone
+	}
+	logger.Info("campaign resumed", logger.Any("campaign", c.id), logger.Any("offset", checkpoint.Offset))
+	return nil
+}
+
+//skip moves the source past the recipients handled before the checkpoint
+func (c *Campaign) skip() error {
+	var rowErr *recipient.RowError
+	for i := int64(0); i < c.Progress().Offset; i++ {
+		if _, err := c.source.Next(); err != nil && !errors.As(err, &rowErr) {
+			return err
+		}
+	}
+	return nil
+}
+
+//wait blocks while the campaign is paused
+func (c *Campaign) wait(ctx context.Context) error {
+	c.locker.Lock()
+	resumed, state := c.resumed, c.progress.State
+	c.locker.Unlock()
+	if state == CampaignCancelled {
+		return ErrCampaignCancelled
+	}
+	if state != CampaignPaused {
+		return ctx.Err()
+	}
+	select {
+	case <-resumed:
+		return ctx.Err()
+	case <-c.cancel:
+		return ErrCampaignCancelled
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//read returns up to a chunk of rows: the valid ones, how many rows were read up to and including each of them
+//and how many were read in all, along with io.EOF once the source is exhausted
+func (c *Campaign) read() ([]*m.SMSContext, []int, int, error) {
+	chunk := make([]*m.SMSContext, 0, c.options.ChunkSize)
+	ends := make([]int, 0, c.options.ChunkSize)
+	rows := 0
+	var rowErr *recipient.RowError
+	for rows < c.options.ChunkSize {
+		context, err := c.source.Next()
+		if errors.As(err, &rowErr) {
+			logger.Error("skipped campaign recipient", logger.Any("campaign", c.id), logger.Err(err))
+			rows++
+			continue
+		}
+		if err != nil {
+			return chunk, ends, rows, err
+		}
+		rows++
+		chunk = append(chunk, context)
+		ends = append(ends, rows)
+	}
+	return chunk, ends, rows, nil
+}
+
+//deliver sends chunk and moves the offset past the rows handled, invalid rows count as failed.
+//Once ctx is done contexts not accepted may never have been tried, so only rows up to the last context of
+//the accepted prefix are handled, the rest are sent again when the campaign resumes.
+func (c *Campaign) deliver(ctx context.Context, chunk []*m.SMSContext, ends []int, rows int) {
+	var succeed []*m.SMSContext
+	if len(chunk) > 0 {
+		var err error
+		if succeed, err = c.send(ctx, chunk); err != nil {
+			logger.Error("failed to send campaign chunk", logger.Any("campaign", c.id), logger.Any("offset", c.Progress().Offset), logger.Err(err))
+		}
+	}
+	if ctx.Err() != nil {
+		if accepted := acceptedPrefix(chunk, succeed); accepted < len(chunk) {
+			succeed, rows = chunk[:accepted], 0
+			if accepted > 0 {
+				rows = ends[accepted-1]
+			}
+		}
+	}
+	if len(succeed) > 0 && c.options.OnSent != nil {
+		c.options.OnSent(succeed)
+	}
+	c.locker.Lock()
+	c.progress.Offset += int64(rows)
+	c.progress.Sent += int64(len(succeed))
+	c.progress.Failed += int64(rows - len(succeed))
+	c.locker.Unlock()
+	c.checkpoint(false)
+}
+
+//acceptedPrefix returns how many contexts from the start of chunk are all in succeed
+func acceptedPrefix(chunk, succeed []*m.SMSContext) int {
+	accepted := make(map[*m.SMSContext]bool, len(succeed))
+	for _, context := range succeed {
+		accepted[context] = true
+	}
+	for i, context := range chunk {
+		if !accepted[context] {
+			return i
+		}
+	}
+	return len(chunk)
+}
+
+//pace sleeps until sending handled recipients since begin respects the rate
+func (c *Campaign) pace(ctx context.Context, begin time.Time, handled int64) error {
+	if c.options.Rate <= 0 {
+		return nil
+	}
+	wait := time.Until(begin.Add(time.Duration(handled) * time.Second / time.Duration(c.options.Rate)))
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-c.cancel:
+		return ErrCampaignCancelled
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Campaign) finish(state CampaignState) {
+	c.locker.Lock()
+	c.progress.State = state
+	c.locker.Unlock()
+	c.checkpoint(true)
+}
+
+//interrupt saves the progress of a campaign stopped before its end, one not cancelled is left interrupted
+func (c *Campaign) interrupt() {
+	c.locker.Lock()
+	if c.progress.State != CampaignCancelled {
+		c.progress.State = CampaignInterrupted
+	}
+	c.locker.Unlock()
+	c.checkpoint(false)
+}
+
+func (c *Campaign) checkpoint(done bool) {
+	progress := c.Progress()
+	if c.options.Checkpoints != nil {
+		err := c.options.Checkpoints.SaveCheckpoint(&Checkpoint{
+			Campaign: c.id,
+			Offset:   progress.Offset,
+			Sent:     progress.Sent,
+			Failed:   progress.Failed,
+			Done:     done,
+			Updated:  time.Now(),
+		})
+		if err != nil {
+			logger.Error("failed to save campaign checkpoint", logger.Any("campaign", c.id), logger.Err(err))
+		}
+	}
+	if c.options.OnProgress != nil {
+		c.options.OnProgress(progress)
+	}
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	c "github.com/linkedin-inc/mane/config"
+	m "github.com/linkedin-inc/mane/model"
+	"github.com/linkedin-inc/mane/recipient"
+	tp "github.com/linkedin-inc/mane/template"
+	v "github.com/linkedin-inc/mane/vendor"
+)
+
+func campaignCSV(count int) string {
+	var builder strings.Builder
+	builder.WriteString("phone,name\n")
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(&builder, "%d,user%d\n", 13800000000+i, i)
+	}
+	return builder.String()
+}
+
+func TestCampaign(t *testing.T) {
+	categories := []tp.SMSCategory{{Name: "campaign", Channel: tp.MarketingChannel}}
+	templates := []tp.SMSTemplate{{Name: "campaign", Category: "campaign", Content: "hi {name}", Enabled: true}}
+	if _, err := c.Apply(categories, templates); err != nil {
+		t.Fatalf("TestCampaign failed. err:%v\n", err)
+	}
+	c.SetRunMode(c.ModeSandbox)
+	defer c.SetRunMode(c.ModeDryRun)
+	sandbox := v.SandboxVendor()
+	sandbox.Reset()
+	dir, err := ioutil.TempDir("", "campaign")
+	if err != nil {
+		t.Fatalf("TestCampaign failed. err:%v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileCheckpointStore(dir)
+
+	//cancelled after the first chunk as if the process stopped
+	var campaign *Campaign
+	campaign = NewCampaign("spring", recipient.NewCSVReader(strings.NewReader(campaignCSV(25)), "campaign"), CampaignOptions{
+		ChunkSize:   10,
+		Checkpoints: store,
+		OnProgress:  func(CampaignProgress) { campaign.Cancel() },
+	})
+	if err = campaign.Run(context.Background()); err != ErrCampaignCancelled {
+		t.Fatalf("TestCampaign failed. err:%v\n", err)
+	}
+	if progress := campaign.Progress(); progress.Offset != 10 || progress.Sent != 10 || progress.State != CampaignCancelled {
+		t.Fatalf("TestCampaign failed. progress:%+v\n", progress)
+	}
+
+	//resumed from the checkpoint, paused and resumed halfway
+	var progresses []CampaignProgress
+	campaign = NewCampaign("spring", recipient.NewCSVReader(strings.NewReader(campaignCSV(25)), "campaign"), CampaignOptions{
+		ChunkSize:   10,
+		Rate:        1000,
+		Checkpoints: store,
+		OnProgress: func(progress CampaignProgress) {
+			progresses = append(progresses, progress)
+			if len(progresses) == 1 {
+				campaign.Pause()
+				go campaign.Resume()
+			}
+		},
+	})
+	if err = campaign.Run(context.Background()); err != nil {
+		t.Fatalf("TestCampaign failed. err:%v\n", err)
+	}
+	if progress := campaign.Progress(); progress.Offset != 25 || progress.Sent != 25 || progress.Failed != 0 || progress.State != CampaignDone {
+		t.Fatalf("TestCampaign failed. progress:%+v\n", progress)
+	}
+	if len(progresses) != 3 || progresses[0].Offset != 20 {
+		t.Fatalf("TestCampaign failed. progresses:%+v\n", progresses)
+	}
+	sent := sandbox.Sent()
+	if len(sent) != 25 || sent[24].Phone != "13800000024" || sent[24].Content != "hi user24" {
+		t.Fatalf("TestCampaign failed. sent:%d\n", len(sent))
+	}
+
+	//a done campaign is not sent again
+	campaign = NewCampaign("spring", recipient.NewCSVReader(strings.NewReader(campaignCSV(25)), "campaign"), CampaignOptions{Checkpoints: store})
+	if err = campaign.Run(context.Background()); err != nil || campaign.Progress().State != CampaignDone || len(sandbox.Sent()) != 25 {
+		t.Fatalf("TestCampaign failed. sent:%d, err:%v\n", len(sandbox.Sent()), err)
+	}
+	if err = campaign.Run(context.Background()); err != ErrCampaignStarted {
+		t.Fatalf("TestCampaign failed. err:%v\n", err)
+	}
+}
+
+func TestCampaign_Pacing(t *testing.T) {
+	var chunks []int
+	campaign := NewCampaign("pacing", recipient.NewCSVReader(strings.NewReader(campaignCSV(300)), "campaign"), CampaignOptions{Rate: 100})
+	campaign.send = func(ctx context.Context, contexts []*m.SMSContext) ([]*m.SMSContext, error) {
+		chunks = append(chunks, len(contexts))
+		return contexts, nil
+	}
+	//the default chunk of 500 is capped to the rate, the next chunk waits for the second to pass
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := campaign.Run(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("TestCampaign_Pacing failed. err:%v\n", err)
+	}
+	if len(chunks) != 1 || chunks[0] != 100 {
+		t.Fatalf("TestCampaign_Pacing failed. chunks:%v\n", chunks)
+	}
+}
+
+func TestCampaign_Interrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "campaign")
+	if err != nil {
+		t.Fatalf("TestCampaign_Interrupted failed. err:%v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileCheckpointStore(dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	campaign := NewCampaign("interrupted", recipient.NewCSVReader(strings.NewReader(campaignCSV(30)), "campaign"), CampaignOptions{
+		ChunkSize:   10,
+		Rate:        10,
+		Checkpoints: store,
+	})
+	campaign.send = func(ctx context.Context, contexts []*m.SMSContext) ([]*m.SMSContext, error) {
+		cancel()
+		return contexts, nil
+	}
+	if err = campaign.Run(ctx); err != context.Canceled {
+		t.Fatalf("TestCampaign_Interrupted failed. err:%v\n", err)
+	}
+	if progress := campaign.Progress(); progress.State != CampaignInterrupted || progress.Offset != 10 {
+		t.Fatalf("TestCampaign_Interrupted failed. progress:%+v\n", progress)
+	}
+	checkpoint, err := store.LoadCheckpoint("interrupted")
+	if err != nil || checkpoint.Offset != 10 || checkpoint.Done {
+		t.Fatalf("TestCampaign_Interrupted failed. checkpoint:%+v, err:%v\n", checkpoint, err)
+	}
+}
+
+func TestCampaign_InterruptedMidChunk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "campaign")
+	if err != nil {
+		t.Fatalf("TestCampaign_InterruptedMidChunk failed. err:%v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileCheckpointStore(dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	campaign := NewCampaign("mid-chunk", recipient.NewCSVReader(strings.NewReader(campaignCSV(30)), "campaign"), CampaignOptions{
+		ChunkSize:   10,
+		Checkpoints: store,
+	})
+	//the second chunk is cancelled after 4 contexts and a later one were accepted
+	chunks := 0
+	campaign.send = func(ctx context.Context, contexts []*m.SMSContext) ([]*m.SMSContext, error) {
+		if chunks++; chunks == 1 {
+			return contexts, nil
+		}
+		cancel()
+		return append(contexts[:4:4], contexts[6]), ctx.Err()
+	}
+	if err = campaign.Run(ctx); err != context.Canceled {
+		t.Fatalf("TestCampaign_InterruptedMidChunk failed. err:%v\n", err)
+	}
+	checkpoint, err := store.LoadCheckpoint("mid-chunk")
+	if err != nil || checkpoint.Offset != 14 || checkpoint.Sent != 14 || checkpoint.Failed != 0 {
+		t.Fatalf("TestCampaign_InterruptedMidChunk failed. checkpoint:%+v, err:%v\n", checkpoint, err)
+	}
+
+	//resumed right after the accepted prefix
+	var sent []string
+	campaign = NewCampaign("mid-chunk", recipient.NewCSVReader(strings.NewReader(campaignCSV(30)), "campaign"), CampaignOptions{
+		ChunkSize:   10,
+		Checkpoints: store,
+	})
+	campaign.send = func(ctx context.Context, contexts []*m.SMSContext) ([]*m.SMSContext, error) {
+		for _, context := range contexts {
+			sent = append(sent, context.Phone)
+		}
+		return contexts, nil
+	}
+	if err = campaign.Run(context.Background()); err != nil {
+		t.Fatalf("TestCampaign_InterruptedMidChunk failed. err:%v\n", err)
+	}
+	if len(sent) != 16 || sent[0] != "13800000014" {
+		t.Fatalf("TestCampaign_InterruptedMidChunk failed. sent:%v\n", sent)
+	}
+	if progress := campaign.Progress(); progress.State != CampaignDone || progress.Offset != 30 || progress.Sent != 30 {
+		t.Fatalf("TestCampaign_InterruptedMidChunk failed. progress:%+v\n", progress)
+	}
+}
+
+func TestFileCheckpointStore_Path(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatalf("TestFileCheckpointStore_Path failed. err:%v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileCheckpointStore(dir)
+	for i, campaign := range []string{"a/x", "b/x", "../x"} {
+		if err = store.SaveCheckpoint(&Checkpoint{Campaign: campaign, Offset: int64(i)}); err != nil {
+			t.Fatalf("TestFileCheckpointStore_Path failed. err:%v\n", err)
+		}
+	}
+	for i, campaign := range []string{"a/x", "b/x", "../x"} {
+		checkpoint, err := store.LoadCheckpoint(campaign)
+		if err != nil || checkpoint.Offset != int64(i) {
+			t.Fatalf("TestFileCheckpointStore_Path failed. campaign:%s, checkpoint:%+v, err:%v\n", campaign, checkpoint, err)
+		}
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 3 {
+		t.Fatalf("TestFileCheckpointStore_Path failed. files:%d\n", len(files))
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+//Checkpoint is how far a campaign got, Offset recipients of its source have been handled
+type Checkpoint struct {
+	Campaign string    `json:"campaign"`
+	Offset   int64     `json:"offset"`
+	Sent     int64     `json:"sent"`
+	Failed   int64     `json:"failed"`
+	Done     bool      `json:"done"`
+	Updated  time.Time `json:"updated"`
+}
+
+//CheckpointStore persists checkpoints of campaigns, implement it on top of your own database
+type CheckpointStore interface {
+	SaveCheckpoint(checkpoint *Checkpoint) error
+	//LoadCheckpoint must fail with ErrCheckpointNotFound if the campaign never saved one
+	LoadCheckpoint(campaign string) (*Checkpoint, error)
+}
+
+//FileCheckpointStore keeps a json file per campaign in a directory, named after the escaped campaign id
+type FileCheckpointStore struct {
+	dir    string
+	locker sync.Mutex
+}
+
+func NewFileCheckpointStore(dir string) *FileCheckpointStore {
+	return &FileCheckpointStore{dir: dir}
+}
+
+//SaveCheckpoint replaces the file atomically, a crash never leaves a partial checkpoint
+func (s *FileCheckpointStore) SaveCheckpoint(checkpoint *Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	path := s.path(checkpoint.Campaign)
+	if err = ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (s *FileCheckpointStore) LoadCheckpoint(campaign string) (*Checkpoint, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	data, err := ioutil.ReadFile(s.path(campaign))
+	if os.IsNotExist(err) {
+		return nil, ErrCheckpointNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var checkpoint Checkpoint
+	if err = json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+//path escapes the whole campaign id, so ids differing only before a separator never share a file or leave dir
+func (s *FileCheckpointStore) path(campaign string) string {
+	return filepath.Join(s.dir, url.PathEscape(campaign)+".checkpoint.json")
+}
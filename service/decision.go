@@ -0,0 +1,23 @@
+package service
+
+import (
+	m "github.com/linkedin-inc/mane/model"
+)
+
+var decisionRecorder func(decisions []*m.Decision)
+
+//RegisterDecisionRecorder makes Send and MultiXSend hand the middleware decisions of every call to record,
+//e.g. to persist them into model.CollSMSDecision. record must not keep contexts waiting, nil turns recording off.
+func RegisterDecisionRecorder(record func(decisions []*m.Decision)) {
+	decisionRecorder = record
+}
+
+//observeDecisions attaches its decision to each of contexts, so that callers can tell why a phone was dropped
+func observeDecisions(contexts []*m.SMSContext, decisions []*m.Decision) {
+	for i := range contexts {
+		contexts[i].Decision = decisions[i]
+	}
+	if decisionRecorder != nil {
+		decisionRecorder(decisions)
+	}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"testing"
+
+	c "github.com/linkedin-inc/mane/config"
+	"github.com/linkedin-inc/mane/middleware"
+	m "github.com/linkedin-inc/mane/model"
+	tp "github.com/linkedin-inc/mane/template"
+	v "github.com/linkedin-inc/mane/vendor"
+)
+
+func TestDecisions(t *testing.T) {
+	categories := []tp.SMSCategory{{Name: "decision", Channel: tp.ProductionChannel}}
+	templates := []tp.SMSTemplate{{Name: "decision", Category: "decision", Content: "验证码 {code}", Enabled: true,
+		ActionList: []middleware.Action{&blockPhone{phone: "13800000001"}}}}
+	if _, err := c.Apply(categories, templates); err != nil {
+		t.Fatalf("TestDecisions failed. err:%v\n", err)
+	}
+	c.SetRunMode(c.ModeSandbox)
+	defer c.SetRunMode(c.ModeDryRun)
+	v.SandboxVendor().Reset()
+	var recorded []*m.Decision
+	RegisterDecisionRecorder(func(decisions []*m.Decision) {
+		recorded = append(recorded, decisions...)
+	})
+	defer RegisterDecisionRecorder(nil)
+
+	contexts := []*m.SMSContext{
+		m.NewSMSContext(1, "13800000000", "decision", map[string]string{"code": "1234"}),
+		m.NewSMSContext(2, "13800000001", "decision", map[string]string{"code": "5678"}),
+	}
+	succeed, err := MultiXSend(contexts)
+	if err != nil || len(succeed) != 1 {
+		t.Fatalf("TestDecisions failed. succeed:%v, err:%v\n", succeed, err)
+	}
+	if decision := contexts[0].Decision; decision == nil || !decision.Allowed || decision.Phone != "13800000000" {
+		t.Fatalf("TestDecisions failed. decision:%+v\n", decision)
+	}
+	if decision := contexts[1].Decision; decision == nil || decision.Allowed || decision.Action != "BlockPhone" || decision.Template != "decision" {
+		t.Fatalf("TestDecisions failed. decision:%+v\n", decision)
+	}
+	if len(recorded) != 2 || recorded[1] != contexts[1].Decision {
+		t.Fatalf("TestDecisions failed. recorded:%+v\n", recorded)
+	}
+}
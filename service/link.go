@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+
+	"github.com/linkedin-inc/mane/logger"
+	t "github.com/linkedin-inc/mane/template"
+	"github.com/linkedin-inc/mane/track"
+)
+
+var (
+	shortener *track.Shortener
+	signer    *track.Signer
+)
+
+//RegisterShortener makes tracked links be replaced by short links of s, long links are sent when nil
+func RegisterShortener(s *track.Shortener) {
+	shortener = s
+}
+
+//RegisterSigner makes tracked links carry a token signed by s instead of plain params, nil turns signing off
+func RegisterSigner(s *track.Signer) {
+	signer = s
+}
+
+//trackLinks adds tracking params to the URLs of content rendered from template when it asks for it.
+//Content with tracked links is never shared, so userID always names its recipient, 0 leaves the user out.
+//Previews get no short links, nothing is saved for them.
+func trackLinks(ctx context.Context, template *t.SMSTemplate, variant string, content string, msgID int64, userID int64) string {
+	if !template.TrackLinks {
+		return content
+	}
+	link := track.TrackableLink{
+		UserID:   userID,
+		TrackID:  track.TrackIDOf(string(template.Name), msgID),
+		Template: string(template.Name),
+		Variant:  variant,
+	}
+	if signer != nil {
+		token, err := signer.Sign(link, msgID)
+		if err != nil {
+			logger.Error("failed to sign links", logger.Template(string(template.Name)), logger.MsgID(msgID), logger.Err(err))
+			return content
+		}
+		link.Token = token
+	}
+	tracked := link.Inject(content)
+	if shortener == nil || isPreview(ctx) {
+		return tracked
+	}
+	shortened, err := shortener.Rewrite(tracked, link, msgID)
+	if err != nil {
+		logger.Error("failed to shorten links", logger.Template(string(template.Name)), logger.MsgID(msgID), logger.Err(err))
+		return tracked
+	}
+	return shortened
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	c "github.com/linkedin-inc/mane/config"
+	m "github.com/linkedin-inc/mane/model"
+	tp "github.com/linkedin-inc/mane/template"
+	"github.com/linkedin-inc/mane/track"
+	v "github.com/linkedin-inc/mane/vendor"
+)
+
+func TestSendTrackedLinks(t *testing.T) {
+	categories := []tp.SMSCategory{{Name: "link", Channel: tp.MarketingChannel}}
+	templates := []tp.SMSTemplate{{Name: "link", Category: "link", Content: "see https://example.com/sale?from=sms {url}", Enabled: true, TrackLinks: true}}
+	if _, err := c.Apply(categories, templates); err != nil {
+		t.Fatalf("TestSendTrackedLinks failed. err:%v\n", err)
+	}
+	c.SetRunMode(c.ModeSandbox)
+	defer c.SetRunMode(c.ModeDryRun)
+	sandbox := v.SandboxVendor()
+	sandbox.Reset()
+
+	context := m.NewSMSContext(1, "13800000000", "link", map[string]string{"url": "http://example.com/item#info"})
+	context.UserID = 42
+	if _, err := MultiXSend([]*m.SMSContext{context}); err != nil {
+		t.Fatalf("TestSendTrackedLinks failed. err:%v\n", err)
+	}
+	history := context.History
+	trackID := track.TrackIDOf("link", history.MsgID)
+	expected := "see https://example.com/sale?from=sms&track_id=" + trackID + "&user_id=42 http://example.com/item?track_id=" + trackID + "&user_id=42#info"
+	if history.Content != expected {
+		t.Fatalf("TestSendTrackedLinks failed. content:%s\n", history.Content)
+	}
+
+	signer, _ := track.NewSigner(track.Key{ID: "k1", Secret: []byte("secret")}, 0)
+	RegisterSigner(signer)
+	context = m.NewSMSContext(4, "13800000000", "link", map[string]string{"url": ""})
+	context.UserID = 42
+	if _, err := Send([]*m.SMSContext{context}); err != nil {
+		t.Fatalf("TestSendTrackedLinks failed. err:%v\n", err)
+	}
+	RegisterSigner(nil)
+	signed := strings.Fields(context.History.Content)[1]
+	token := signed[strings.Index(signed, "token=")+len("token="):]
+	if link, msgID, err := signer.Verify(token); err != nil || link.UserID != 42 || msgID != context.History.MsgID {
+		t.Fatalf("TestSendTrackedLinks failed. content:%s, err:%v\n", context.History.Content, err)
+	}
+
+	store := track.NewMemoryStore()
+	RegisterShortener(track.NewShortener(store, "https://t.example.com/"))
+	defer RegisterShortener(nil)
+	contexts := []*m.SMSContext{
+		m.NewSMSContext(2, "13800000001", "link", map[string]string{"url": ""}),
+		m.NewSMSContext(3, "13800000002", "link", map[string]string{"url": ""}),
+	}
+	contexts[0].UserID, contexts[1].UserID = 7, 8
+	sandbox.Reset()
+	if _, err := Send(contexts); err != nil {
+		t.Fatalf("TestSendTrackedLinks failed. err:%v\n", err)
+	}
+	//tracked links are rendered per recipient, so each keeps its user, and they go out in one MultiXSend request
+	if contexts[0].History.MsgID == contexts[1].History.MsgID || contexts[0].History.Content == contexts[1].History.Content || sandbox.Requests() != 1 {
+		t.Fatalf("TestSendTrackedLinks failed. contents:%s, %s, requests:%d\n", contexts[0].History.Content, contexts[1].History.Content, sandbox.Requests())
+	}
+	for i, context := range contexts {
+		if !strings.HasPrefix(context.History.Content, "see https://t.example.com/") {
+			t.Fatalf("TestSendTrackedLinks failed. content:%s\n", context.History.Content)
+		}
+		code := strings.TrimSpace(strings.TrimPrefix(context.History.Content, "see https://t.example.com/"))
+		link, err := store.FindLink(code)
+		if err != nil || link.UserID != int64(7+i) || link.TrackID != track.TrackIDOf("link", context.History.MsgID) {
+			t.Fatalf("TestSendTrackedLinks failed. link:%+v, err:%v\n", link, err)
+		}
+	}
+}
+
+func TestSendTrackedLinks_Users(t *testing.T) {
+	categories := []tp.SMSCategory{{Name: "link", Channel: tp.MarketingChannel}}
+	templates := []tp.SMSTemplate{{Name: "users", Category: "link", Content: "see https://example.com/sale", Enabled: true, TrackLinks: true}}
+	if _, err := c.Apply(categories, templates); err != nil {
+		t.Fatalf("TestSendTrackedLinks_Users failed. err:%v\n", err)
+	}
+	c.SetRunMode(c.ModeSandbox)
+	defer c.SetRunMode(c.ModeDryRun)
+	v.SandboxVendor().Reset()
+	signer, _ := track.NewSigner(track.Key{ID: "k1", Secret: []byte("secret")}, 0)
+	RegisterSigner(signer)
+	defer RegisterSigner(nil)
+
+	contexts := []*m.SMSContext{
+		m.NewSMSContext(1, "13800000001", "users", nil),
+		m.NewSMSContext(2, "13800000002", "users", nil),
+		m.NewSMSContext(3, "13800000003", "users", nil),
+	}
+	for i := range contexts {
+		contexts[i].UserID = int64(100 + i)
+	}
+	if _, err := Send(contexts); err != nil {
+		t.Fatalf("TestSendTrackedLinks_Users failed. err:%v\n", err)
+	}
+	for i, context := range contexts {
+		signed := strings.Fields(context.History.Content)[1]
+		token := signed[strings.Index(signed, "token=")+len("token="):]
+		link, msgID, err := signer.Verify(token)
+		if err != nil || link.UserID != int64(100+i) || msgID != context.History.MsgID {
+			t.Fatalf("TestSendTrackedLinks_Users failed. content:%s, link:%+v, err:%v\n", context.History.Content, link, err)
+		}
+	}
+}
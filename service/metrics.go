@@ -0,0 +1,50 @@
+package service
+
+import (
+	"time"
+
+	"github.com/linkedin-inc/mane/metrics"
+	m "github.com/linkedin-inc/mane/model"
+	t "github.com/linkedin-inc/mane/template"
+	v "github.com/linkedin-inc/mane/vendor"
+)
+
+//observeSend records contexts handed to Send or MultiXSend, what became of them is recorded by deliver
+func observeSend(contexts []*m.SMSContext) {
+	metrics.SMSAttempted.Add(float64(len(contexts)), sendLabels(contexts)...)
+}
+
+//observeVendor records how many of contexts handed to the vendor it accepted, the rest count as failed
+func observeVendor(contexts []*m.SMSContext, succeedCount int) {
+	labels := sendLabels(contexts)
+	metrics.SMSAccepted.Add(float64(succeedCount), labels...)
+	metrics.SMSFailed.Add(float64(len(contexts)-succeedCount), labels...)
+}
+
+//observeDiscard records contexts the run mode kept from the vendor
+func observeDiscard(contexts []*m.SMSContext, reason string) {
+	metrics.SMSDiscarded.Add(float64(len(contexts)), append(sendLabels(contexts), reason)...)
+}
+
+//sendLabels takes template, category, channel and vendor from the first assembled history,
+//only the template is known when assembling failed
+func sendLabels(contexts []*m.SMSContext) []string {
+	for _, context := range contexts {
+		if history := context.History; history != nil {
+			return []string{history.Template, history.Category, t.Channel(history.Channel).String(), history.Vendor}
+		}
+	}
+	return []string{contexts[0].Template, "", "", ""}
+}
+
+func observeStatus(name v.Name, statuses []*m.DeliveryStatus) {
+	now := time.Now()
+	for _, status := range statuses {
+		result := metrics.ResultDelivered
+		if status.StatusCode != 0 {
+			result = metrics.ResultFailed
+		}
+		metrics.DeliveryStatus.Inc(string(name), result)
+		metrics.PullLag.Observe(now.Sub(status.Timestamp).Seconds(), string(name))
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	c "github.com/linkedin-inc/mane/config"
+	"github.com/linkedin-inc/mane/logger"
+	"github.com/linkedin-inc/mane/metrics"
+	m "github.com/linkedin-inc/mane/model"
+	t "github.com/linkedin-inc/mane/template"
+	v "github.com/linkedin-inc/mane/vendor"
+)
+
+//route returns the vendor used for operation on channel under current run mode
+func route(channel t.Channel, operation string) (v.Vendor, error) {
+	if c.CurrentRunMode() == c.ModeSandbox {
+		return v.SandboxVendor(), nil
+	}
+	return v.GetByChannelFor(channel, operation)
+}
+
+//routeByName returns the vendors pulled for name under current run mode
+func routeByName(name v.Name) ([]v.Vendor, error) {
+	if c.CurrentRunMode() == c.ModeSandbox {
+		return []v.Vendor{v.SandboxVendor()}, nil
+	}
+	return v.GetByName(name)
+}
+
+//deliver hands contexts to send under current run mode, contexts not really sent are reported as succeed
+func deliver(contexts []*m.SMSContext, send func([]*m.SMSContext) ([]*m.SMSContext, error)) ([]*m.SMSContext, error) {
+	switch c.CurrentRunMode() {
+	case c.ModeDryRun:
+		logger.Info("discard due to dry run", logger.Template(contexts[0].Template), logger.Any("count", len(contexts)))
+		observeDiscard(contexts, metrics.ReasonDryRun)
+		return contexts, nil
+	case c.ModeWhitelist:
+		var whitelisted, discarded []*m.SMSContext
+		for _, context := range contexts {
+			if c.Whitelisted(context.Phone) {
+				whitelisted = append(whitelisted, context)
+			} else {
+				discarded = append(discarded, context)
+			}
+		}
+		if len(discarded) > 0 {
+			logger.Info("discard due to not in whitelist", logger.Template(contexts[0].Template), logger.Any("count", len(discarded)))
+			observeDiscard(discarded, metrics.ReasonWhitelist)
+		}
+		if len(whitelisted) == 0 {
+			return discarded, nil
+		}
+		succeedContexts, err := send(whitelisted)
+		observeVendor(whitelisted, len(succeedContexts))
+		if err != nil {
+			return nil, err
+		}
+		return append(succeedContexts, discarded...), nil
+	default:
+		succeedContexts, err := send(contexts)
+		observeVendor(contexts, len(succeedContexts))
+		return succeedContexts, err
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"regexp"
+	"testing"
+
+	c "github.com/linkedin-inc/mane/config"
+	"github.com/linkedin-inc/mane/metrics"
+	m "github.com/linkedin-inc/mane/model"
+	tp "github.com/linkedin-inc/mane/template"
+	v "github.com/linkedin-inc/mane/vendor"
+)
+
+func prepareModeTemplate() {
+	categories := []tp.SMSCategory{{Name: "mode_test", Channel: tp.InternalChannel}}
+	templates := []tp.SMSTemplate{{Name: "mode_test", Category: "mode_test", Content: "code {code}", Enabled: true}}
+	if _, err := c.Apply(categories, templates); err != nil {
+		panic(err)
+	}
+}
+
+func newModeContexts(phones ...string) []*m.SMSContext {
+	contexts := make([]*m.SMSContext, len(phones))
+	for i, phone := range phones {
+		contexts[i] = m.NewSMSContext(int64(i+1), phone, "mode_test", map[string]string{"code": "1234"})
+	}
+	return contexts
+}
+
+func TestSendSandbox(t *testing.T) {
+	prepareModeTemplate()
+	c.SetRunMode(c.ModeSandbox)
+	defer c.SetRunMode(c.ModeDryRun)
+	sandbox := v.SandboxVendor()
+	sandbox.Reset()
+	sandbox.Script(v.FakeRule{Reply: "TD"})
+	defer sandbox.Script()
+
+	succeed, err := Send(newModeContexts("13800000001", "13800000002"))
+	if err != nil || len(succeed) != 2 {
+		t.Fatalf("TestSendSandbox failed. succeed:%d, err:%v\n", len(succeed), err)
+	}
+	sent := sandbox.Sent()
+	if len(sent) != 2 || sent[0].Content != "code 1234" || sent[0].Vendor != string(v.NameSandbox) {
+		t.Fatalf("TestSendSandbox failed. sent:%v\n", sent)
+	}
+	statuses, replies, err := Pull(v.NameMontnets)
+	if err != nil || len(statuses) != 2 || len(replies) != 2 {
+		t.Fatalf("TestSendSandbox failed. statuses:%d, replies:%d, err:%v\n", len(statuses), len(replies), err)
+	}
+	if statuses[0].MsgID != sent[0].MsgID || replies[1].Msg != "TD" {
+		t.Fatalf("TestSendSandbox failed. status:%v, reply:%v\n", statuses[0], replies[1])
+	}
+}
+
+func TestSendWhitelist(t *testing.T) {
+	prepareModeTemplate()
+	c.SetRunMode(c.ModeWhitelist, "13800000001")
+	defer c.SetRunMode(c.ModeDryRun)
+
+	labels := []string{"mode_test", "", "", ""}
+	accepted, failed := metrics.SMSAccepted.Value(labels...), metrics.SMSFailed.Value(labels...)
+	whitelist, dryRun := metrics.SMSDiscarded.Value(append(labels, metrics.ReasonWhitelist)...), metrics.SMSDiscarded.Value(append(labels, metrics.ReasonDryRun)...)
+	var sent []*m.SMSContext
+	succeed, err := deliver(newModeContexts("13800000001", "13800000002"), func(contexts []*m.SMSContext) ([]*m.SMSContext, error) {
+		sent = contexts
+		return contexts, nil
+	})
+	if err != nil || len(succeed) != 2 {
+		t.Fatalf("TestSendWhitelist failed. succeed:%d, err:%v\n", len(succeed), err)
+	}
+	if len(sent) != 1 || sent[0].Phone != "13800000001" {
+		t.Fatalf("TestSendWhitelist failed. sent:%v\n", sent)
+	}
+
+	c.SetRunMode(c.ModeDryRun)
+	sent = nil
+	succeed, err = deliver(newModeContexts("13800000001"), func(contexts []*m.SMSContext) ([]*m.SMSContext, error) {
+		sent = contexts
+		return contexts, nil
+	})
+	if err != nil || len(succeed) != 1 || sent != nil {
+		t.Fatalf("TestSendWhitelist failed. dry run sent:%v, err:%v\n", sent, err)
+	}
+	// discarded messages are neither accepted nor failed
+	if metrics.SMSAccepted.Value(labels...)-accepted != 1 || metrics.SMSFailed.Value(labels...) != failed ||
+		metrics.SMSDiscarded.Value(append(labels, metrics.ReasonWhitelist)...)-whitelist != 1 ||
+		metrics.SMSDiscarded.Value(append(labels, metrics.ReasonDryRun)...)-dryRun != 1 {
+		t.Fatal("TestSendWhitelist failed. metrics are not counted apart")
+	}
+}
+
+func TestSendFake(t *testing.T) {
+	prepareModeTemplate()
+	fake := v.NewFake("fake", v.FakeRule{Pattern: regexp.MustCompile(`2$`), Reject: true})
+	v.Register(tp.InternalChannel, fake)
+	c.SetRunMode(c.ModeProduction)
+	defer c.SetRunMode(c.ModeDryRun)
+
+	succeed, err := MultiXSend(newModeContexts("13800000001", "13800000002", "13800000003"))
+	if err != nil || len(succeed) != 2 {
+		t.Fatalf("TestSendFake failed. succeed:%d, err:%v\n", len(succeed), err)
+	}
+	if err := fake.ExpectSent("13800000001", "13800000003"); err != nil {
+		t.Fatalf("TestSendFake failed. err:%v\n", err)
+	}
+	if err := fake.ExpectContent("13800000003", "code 1234"); err != nil {
+		t.Fatalf("TestSendFake failed. err:%v\n", err)
+	}
+}
+
+func TestSendPinnedVersion(t *testing.T) {
+	categories := []tp.SMSCategory{{Name: "pinned", Channel: tp.InternalChannel}}
+	for i, content := range []string{"old {code}", "new {code}"} {
+		templates := []tp.SMSTemplate{{Name: "pinned", Category: "pinned", Content: content, Enabled: true, Timestamp: int64(i + 1)}}
+		if _, err := c.Apply(categories, templates); err != nil {
+			t.Fatalf("TestSendPinnedVersion failed. err:%v\n", err)
+		}
+	}
+	c.SetRunMode(c.ModeSandbox)
+	defer c.SetRunMode(c.ModeDryRun)
+	sandbox := v.SandboxVendor()
+	sandbox.Reset()
+
+	pinned := m.NewSMSContext(1, "13800000001", "pinned", map[string]string{"code": "1234"})
+	pinned.TemplateVersion = 1
+	if _, err := Send([]*m.SMSContext{pinned}); err != nil {
+		t.Fatalf("TestSendPinnedVersion failed. err:%v\n", err)
+	}
+	latest := m.NewSMSContext(2, "13800000002", "pinned", map[string]string{"code": "1234"})
+	if _, err := Send([]*m.SMSContext{latest}); err != nil {
+		t.Fatalf("TestSendPinnedVersion failed. err:%v\n", err)
+	}
+	sent := sandbox.Sent()
+	if sent[0].Content != "old 1234" || sent[0].TemplateVersion != 1 || sent[1].Content != "new 1234" || sent[1].TemplateVersion != 2 {
+		t.Fatalf("TestSendPinnedVersion failed. sent:%v, %v\n", sent[0], sent[1])
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+
+	"github.com/linkedin-inc/mane/middleware"
+	m "github.com/linkedin-inc/mane/model"
+	t "github.com/linkedin-inc/mane/template"
+	"github.com/linkedin-inc/mane/trace"
+	v "github.com/linkedin-inc/mane/vendor"
+)
+
+//PreviewMessage is a message that would be sent
+type PreviewMessage struct {
+	History  *m.SMSHistory
+	Segments int
+}
+
+//Preview is what a send would do, without any vendor being called
+type Preview struct {
+	Template        string
+	TemplateVersion int64
+	Channel         t.Channel
+	Vendor          v.Name
+	Messages        []PreviewMessage
+	//Blocked are the decisions of the middleware dropping a recipient, along with the recipients
+	//an open circuit or the balance reserve would stop, Vendor is empty when no vendor is available
+	Blocked []*m.Decision
+	//Segments is the sum of the segments of all messages, what the send would be billed
+	Segments int
+}
+
+//PreviewSend tells what Send would do with contexts: the template version, channel and vendor picked,
+//which action would block which phone, and the rendered histories. contexts are left untouched.
+//Actions run in dry mode, see middleware.WithDryRun, so a preview is neither counted nor remembered by them.
+func PreviewSend(ctx context.Context, contexts []*m.SMSContext) (*Preview, error) {
+	return preview(ctx, contexts, render)
+}
+
+//PreviewMultiXSend is PreviewSend for MultiXSend, every context is rendered with its own variables
+func PreviewMultiXSend(ctx context.Context, contexts []*m.SMSContext) (*Preview, error) {
+	return preview(ctx, contexts, renderMulti)
+}
+
+//vendorBlockers name what stops a send after the actions, a preview reports them as blocks instead of failing
+var vendorBlockers = map[error]string{
+	v.ErrCircuitOpen:   "CircuitBreaker",
+	ErrBalanceReserved: "BalanceWatcher",
+}
+
+type previewKey struct{}
+
+func isPreview(ctx context.Context) bool {
+	return ctx.Value(previewKey{}) != nil
+}
+
+func preview(ctx context.Context, contexts []*m.SMSContext,
+	render func(context.Context, *t.SMSTemplate, t.Channel, v.Vendor, []*m.SMSContext) error) (*Preview, error) {
+	if len(contexts) == 0 {
+		return nil, ErrInvalidPhoneArray
+	}
+	ctx, span := trace.Start(middleware.WithDryRun(context.WithValue(ctx, previewKey{}, true)), trace.SpanPreview)
+	defer span.End()
+	span.SetAttribute("template", contexts[0].Template)
+	span.SetAttribute("count", len(contexts))
+	_, configSpan := trace.Start(ctx, trace.SpanConfig)
+	template, channel, err := resolve(configSpan, contexts[0].Template, contexts[0].TemplateVersion)
+	configSpan.End()
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	result := &Preview{
+		Template:        string(template.Name),
+		TemplateVersion: template.Version(),
+		Channel:         channel,
+	}
+	vendor, err := route(channel, v.OpSend)
+	if err == nil {
+		result.Vendor = vendor.Name()
+		err = checkBalance(vendor, channel)
+	}
+	blocker, blocking := vendorBlockers[err]
+	if err != nil && !blocking {
+		span.RecordError(err)
+		return nil, err
+	}
+	copies := make([]*m.SMSContext, len(contexts))
+	for i := range contexts {
+		copied := *contexts[i]
+		copied.History, copied.Decision = nil, nil
+		copies[i] = &copied
+	}
+	allowedContexts, decisions := middleware.NewMiddleware(template.ActionList...).Decide(ctx, copies)
+	for _, decision := range decisions {
+		if !decision.Allowed {
+			result.Blocked = append(result.Blocked, decision)
+		}
+	}
+	if blocking {
+		//whatever the actions let through would be stopped before the vendor
+		for i, decision := range decisions {
+			if decision.Allowed {
+				result.Blocked = append(result.Blocked, &m.Decision{MID: copies[i].ID, Phone: copies[i].Phone, Template: copies[i].Template,
+					Action: blocker, Reason: err.Error(), Timestamp: decision.Timestamp})
+			}
+		}
+		return result, nil
+	}
+	if len(allowedContexts) == 0 {
+		return result, nil
+	}
+	if err = render(ctx, template, channel, vendor, allowedContexts); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	for _, context := range allowedContexts {
+		segments := t.Segments(context.History.Content)
+		result.Messages = append(result.Messages, PreviewMessage{History: context.History, Segments: segments})
+		result.Segments += segments
+	}
+	return result, nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	c "github.com/linkedin-inc/mane/config"
+	"github.com/linkedin-inc/mane/metrics"
+	"github.com/linkedin-inc/mane/middleware"
+	m "github.com/linkedin-inc/mane/model"
+	tp "github.com/linkedin-inc/mane/template"
+	"github.com/linkedin-inc/mane/track"
+	v "github.com/linkedin-inc/mane/vendor"
+)
+
+type blockPhone struct {
+	phone string
+}
+
+func (*blockPhone) Name() string {
+	return "BlockPhone"
+}
+
+func (a *blockPhone) Call(context *m.SMSContext, next func() bool) bool {
+	if context.Phone == a.phone {
+		return false
+	}
+	return next()
+}
+
+func (a *blockPhone) Unmarshal(middleware.ActionStruct) (middleware.Action, error) {
+	return a, nil
+}
+
+//countPhone blocks a phone once it was sent to, like a dedupe action
+type countPhone struct {
+	sent map[string]int
+}
+
+func (*countPhone) Name() string {
+	return "CountPhone"
+}
+
+func (a *countPhone) Call(context *m.SMSContext, next func() bool) bool {
+	if a.sent[context.Phone] > 0 {
+		return false
+	}
+	a.sent[context.Phone]++
+	return next()
+}
+
+func (a *countPhone) DryCall(context *m.SMSContext, next func() bool) bool {
+	if a.sent[context.Phone] > 0 {
+		return false
+	}
+	return next()
+}
+
+func (a *countPhone) Unmarshal(middleware.ActionStruct) (middleware.Action, error) {
+	return a, nil
+}
+
+func TestPreview(t *testing.T) {
+	categories := []tp.SMSCategory{{Name: "preview", Channel: tp.ProductionChannel}}
+	templates := []tp.SMSTemplate{{Name: "preview", Category: "preview", Content: "验证码 {code} https://example.com/a", Enabled: true, TrackLinks: true,
+		ActionList: []middleware.Action{&blockPhone{phone: "13800000001"}}}}
+	if _, err := c.Apply(categories, templates); err != nil {
+		t.Fatalf("TestPreview failed. err:%v\n", err)
+	}
+	c.SetRunMode(c.ModeSandbox)
+	defer c.SetRunMode(c.ModeDryRun)
+	sandbox := v.SandboxVendor()
+	sandbox.Reset()
+	RegisterShortener(track.NewShortener(track.NewMemoryStore(), "https://t.example.com/"))
+	defer RegisterShortener(nil)
+
+	contexts := []*m.SMSContext{
+		m.NewSMSContext(1, "13800000000", "preview", map[string]string{"code": "1234"}),
+		m.NewSMSContext(2, "13800000001", "preview", map[string]string{"code": "5678"}),
+	}
+	result, err := PreviewMultiXSend(context.Background(), contexts)
+	if err != nil {
+		t.Fatalf("TestPreview failed. err:%v\n", err)
+	}
+	if result.Vendor != v.NameSandbox || result.Channel != tp.ProductionChannel || result.Template != "preview" {
+		t.Fatalf("TestPreview failed. preview:%+v\n", result)
+	}
+	if len(result.Blocked) != 1 || result.Blocked[0].Phone != "13800000001" || result.Blocked[0].Action != "BlockPhone" {
+		t.Fatalf("TestPreview failed. blocked:%+v\n", result.Blocked)
+	}
+	if len(result.Messages) != 1 || result.Segments != 1 || result.Messages[0].History.Phone != "13800000000" {
+		t.Fatalf("TestPreview failed. messages:%+v\n", result.Messages)
+	}
+	//links are tracked but not shortened, and nothing is sent or touched
+	expected := "验证码 1234 https://example.com/a?track_id=" + track.TrackIDOf("preview", result.Messages[0].History.MsgID)
+	if content := result.Messages[0].History.Content; content != expected {
+		t.Fatalf("TestPreview failed. content:%s\n", content)
+	}
+	if sandbox.Requests() != 0 || contexts[0].History != nil {
+		t.Fatalf("TestPreview failed. requests:%d, history:%v\n", sandbox.Requests(), contexts[0].History)
+	}
+
+	result, err = PreviewSend(context.Background(), contexts[1:])
+	if err != nil || len(result.Messages) != 0 || len(result.Blocked) != 1 {
+		t.Fatalf("TestPreview failed. preview:%+v, err:%v\n", result, err)
+	}
+}
+
+func TestPreview_DryRun(t *testing.T) {
+	counter := &countPhone{sent: map[string]int{"13800000001": 1}}
+	categories := []tp.SMSCategory{{Name: "dry", Channel: tp.MarketingChannel}}
+	templates := []tp.SMSTemplate{{Name: "dry", Category: "dry", Content: "sale", Enabled: true, ActionList: []middleware.Action{counter}}}
+	if _, err := c.Apply(categories, templates); err != nil {
+		t.Fatalf("TestPreview_DryRun failed. err:%v\n", err)
+	}
+	c.SetRunMode(c.ModeSandbox)
+	defer c.SetRunMode(c.ModeDryRun)
+	dropped := metrics.MiddlewareDropped.Value("CountPhone")
+
+	contexts := []*m.SMSContext{
+		m.NewSMSContext(1, "13800000000", "dry", nil),
+		m.NewSMSContext(2, "13800000001", "dry", nil),
+	}
+	result, err := PreviewSend(context.Background(), contexts)
+	if err != nil || len(result.Blocked) != 1 || result.Blocked[0].Action != "CountPhone" || len(result.Messages) != 1 {
+		t.Fatalf("TestPreview_DryRun failed. preview:%+v, err:%v\n", result, err)
+	}
+	//the preview is neither remembered by the action nor counted as dropped
+	if counter.sent["13800000000"] != 0 || metrics.MiddlewareDropped.Value("CountPhone") != dropped {
+		t.Fatalf("TestPreview_DryRun failed. sent:%v, dropped:%v\n", counter.sent, metrics.MiddlewareDropped.Value("CountPhone")-dropped)
+	}
+
+	//a reserved balance blocks what the actions let through instead of failing the preview
+	watcher := NewBalanceWatcher(time.Hour, BalanceThreshold{Alert: 500, Reserve: 100})
+	watcher.balances[v.QuotaKeyOf(v.SandboxVendor())] = &m.Balance{Amount: 10, Unit: m.BalanceUnitMessage, Timestamp: time.Now()}
+	RegisterBalanceWatcher(watcher)
+	defer RegisterBalanceWatcher(nil)
+	result, err = PreviewSend(context.Background(), contexts)
+	if err != nil || len(result.Blocked) != 2 || len(result.Messages) != 0 || result.Vendor != v.NameSandbox {
+		t.Fatalf("TestPreview_DryRun balance failed. preview:%+v, err:%v\n", result, err)
+	}
+	if blocked := result.Blocked[1]; blocked.Phone != "13800000000" || blocked.Action != "BalanceWatcher" || blocked.Reason != ErrBalanceReserved.Error() {
+		t.Fatalf("TestPreview_DryRun balance failed. blocked:%+v\n", blocked)
+	}
+	if _, err = Send(contexts); err != ErrBalanceReserved {
+		t.Fatalf("TestPreview_DryRun balance failed. send err:%v\n", err)
+	}
+}
@@ -1,36 +1,49 @@
 package service
 
 import (
+	"context"
+
 	"github.com/linkedin-inc/mane/logger"
 	m "github.com/linkedin-inc/mane/model"
+	"github.com/linkedin-inc/mane/trace"
 	v "github.com/linkedin-inc/mane/vendor"
 )
 
 func Pull(name v.Name) ([]*m.DeliveryStatus, []*m.Reply, error) {
+	return PullContext(context.Background(), name)
+}
+
+//PullContext is Pull with a span for each iteration attached to the trace carried by ctx
+func PullContext(ctx context.Context, name v.Name) ([]*m.DeliveryStatus, []*m.Reply, error) {
+	ctx, span := trace.Start(ctx, trace.SpanPull)
+	defer span.End()
+	span.SetAttribute("vendor", string(name))
 	var statusList []*m.DeliveryStatus
 	var replyList []*m.Reply
 
-	vendors, err := v.GetByName(name)
+	vendors, err := routeByName(name)
 	if err != nil {
-		logger.E("occur error when find vendor %v : %v\n", name, err)
+		logger.Error("occur error when find vendor", logger.Vendor(string(name)), logger.Err(err))
+		span.RecordError(err)
 		return nil, nil, err
 	}
 	for _, vendor := range vendors {
 		for {
-			statuses, err := fetchStatus(vendor)
+			statuses, err := fetchStatus(ctx, vendor)
 			if err != nil {
-				logger.E("failed to pull status from %v : %v\n", vendor.Name(), err)
+				logger.Error("failed to pull status", logger.Vendor(string(vendor.Name())), logger.Err(err))
 				break
 			}
 			if len(statuses) == 0 {
 				break
 			}
+			observeStatus(vendor.Name(), statuses)
 			statusList = append(statusList, statuses...)
 		}
 		for {
-			replies, err := fetchReply(vendor)
+			replies, err := fetchReply(ctx, vendor)
 			if err != nil {
-				logger.E("failed to pull reply from %v : %v\n", vendor.Name(), err)
+				logger.Error("failed to pull reply", logger.Vendor(string(vendor.Name())), logger.Err(err))
 				break
 			}
 			if len(replies) == 0 {
@@ -39,21 +52,31 @@ func Pull(name v.Name) ([]*m.DeliveryStatus, []*m.Reply, error) {
 			replyList = append(replyList, replies...)
 		}
 	}
+	span.SetAttribute("statuses", len(statusList))
+	span.SetAttribute("replies", len(replyList))
 	return statusList, replyList, nil
 }
 
-func fetchStatus(vendor v.Vendor) ([]*m.DeliveryStatus, error) {
+func fetchStatus(ctx context.Context, vendor v.Vendor) ([]*m.DeliveryStatus, error) {
+	_, span := trace.Start(ctx, trace.SpanPullStatus)
+	defer span.End()
 	statuses, err := vendor.Status()
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
+	span.SetAttribute("count", len(statuses))
 	return statuses, nil
 }
 
-func fetchReply(vendor v.Vendor) ([]*m.Reply, error) {
+func fetchReply(ctx context.Context, vendor v.Vendor) ([]*m.Reply, error) {
+	_, span := trace.Start(ctx, trace.SpanPullReply)
+	defer span.End()
 	replies, err := vendor.Reply()
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
+	span.SetAttribute("count", len(replies))
 	return replies, nil
 }
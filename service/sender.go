@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/linkedin-inc/mane/middleware"
 	m "github.com/linkedin-inc/mane/model"
 	t "github.com/linkedin-inc/mane/template"
+	"github.com/linkedin-inc/mane/trace"
 	v "github.com/linkedin-inc/mane/vendor"
 )
 
@@ -22,20 +24,40 @@ var (
 	ErrNetwork           = errors.New("network error")
 )
 
-// NOTE: each template and variables in context must be the same, and the id field must be unique and not empty
+// NOTE: each template, template version and variables in context must be the same, and the id field must be unique and not empty.
+// Contexts of templates tracking links get a content each, naming their user in the links, and are sent as one MultiXSend batch.
 func Send(contexts []*m.SMSContext) ([]*m.SMSContext, error) {
+	return SendContext(context.Background(), contexts)
+}
+
+// SendContext is Send with spans attached to the trace carried by ctx
+func SendContext(ctx context.Context, contexts []*m.SMSContext) ([]*m.SMSContext, error) {
 	if len(contexts) == 0 {
 		return nil, ErrInvalidPhoneArray
 	}
-	allowedContexts, vendor, err := assembleMetaData(contexts)
+	ctx, span := trace.Start(ctx, trace.SpanSend)
+	defer span.End()
+	span.SetAttribute("template", contexts[0].Template)
+	span.SetAttribute("count", len(contexts))
+	allowedContexts, vendor, err := assembleMetaData(ctx, contexts)
+	observeSend(contexts)
 	if err != nil {
-		logger.E("occur error when Send sms: %v\n", err)
+		logger.Error("occur error when Send sms", logger.Template(contexts[0].Template), logger.Err(err))
+		span.RecordError(err)
 		return nil, err
 	}
-	succeedContexts, err := vendor.Send(allowedContexts)
-	if err != nil && err != v.ErrNotInProduction {
+	channel := t.Channel(allowedContexts[0].History.Channel)
+	ctx, vendor = v.WithChannel(ctx, channel), v.Failover(channel, vendor, checkBalance)
+	succeedContexts, err := deliver(allowedContexts, func(contexts []*m.SMSContext) ([]*m.SMSContext, error) {
+		succeed, err := sendByContent(ctx, span, vendor, contexts)
+		observeConversion(succeed)
+		return succeed, err
+	})
+	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
+	span.SetAttribute("succeed", len(succeedContexts))
 	// only happen when http request failed
 	if len(succeedContexts) == 0 {
 		return nil, ErrNetwork
@@ -43,23 +65,42 @@ func Send(contexts []*m.SMSContext) ([]*m.SMSContext, error) {
 	return succeedContexts, nil
 }
 
-// NOTE: each template in context must be the same, and the id field must be unique and not empty
+// NOTE: each template and template version in context must be the same, and the id field must be unique and not empty
 func MultiXSend(contexts []*m.SMSContext) ([]*m.SMSContext, error) {
+	return MultiXSendContext(context.Background(), contexts)
+}
+
+// MultiXSendContext is MultiXSend with spans attached to the trace carried by ctx
+func MultiXSendContext(ctx context.Context, contexts []*m.SMSContext) ([]*m.SMSContext, error) {
 	if len(contexts) == 0 {
 		return nil, ErrInvalidPhoneArray
 	}
 	if len(contexts) == 1 {
-		return Send(contexts)
+		return SendContext(ctx, contexts)
 	}
-	allowedContexts, vendor, err := assembleMultiMetaData(contexts)
+	ctx, span := trace.Start(ctx, trace.SpanMultiXSend)
+	defer span.End()
+	span.SetAttribute("template", contexts[0].Template)
+	span.SetAttribute("count", len(contexts))
+	allowedContexts, vendor, err := assembleMultiMetaData(ctx, contexts)
+	observeSend(contexts)
 	if err != nil {
-		logger.E("occur error when MultiXSend sms: %v\n", err)
+		logger.Error("occur error when MultiXSend sms", logger.Template(contexts[0].Template), logger.Err(err))
+		span.RecordError(err)
 		return nil, err
 	}
-	succeedContexts, err := vendor.MultiXSend(allowedContexts)
-	if err != nil && err != v.ErrNotInProduction {
+	channel := t.Channel(allowedContexts[0].History.Channel)
+	ctx, vendor = v.WithChannel(ctx, channel), v.Failover(channel, vendor, checkBalance)
+	succeedContexts, err := deliver(allowedContexts, func(contexts []*m.SMSContext) ([]*m.SMSContext, error) {
+		succeed, err := v.MultiXSendContext(ctx, vendor, contexts)
+		observeConversion(succeed)
+		return succeed, err
+	})
+	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
+	span.SetAttribute("succeed", len(succeedContexts))
 	// only happen when http request failed
 	if len(succeedContexts) == 0 {
 		return nil, ErrNetwork
@@ -67,81 +108,146 @@ func MultiXSend(contexts []*m.SMSContext) ([]*m.SMSContext, error) {
 	return succeedContexts, nil
 }
 
-func assembleMetaData(contexts []*m.SMSContext) ([]*m.SMSContext, v.Vendor, error) {
-	template, err := c.WhichTemplate(t.Name(contexts[0].Template))
+// lookup resolves template, channel and vendor used to send contexts of template name by operation,
+// a version other than 0 pins the template to that version.
+func lookup(ctx context.Context, name string, version int64, operation string) (*t.SMSTemplate, t.Channel, v.Vendor, error) {
+	_, span := trace.Start(ctx, trace.SpanConfig)
+	defer span.End()
+	template, channel, err := resolve(span, name, version)
 	if err != nil {
-		logger.E("occur error when assembleMetaData: %v\n", err)
-		return nil, nil, err
+		return nil, t.UnknownChannel, nil, err
 	}
-	allowedContexts := middleware.NewMiddleware(template.ActionList...).Call(contexts)
-	if len(allowedContexts) == 0 {
-		return nil, nil, ErrNotAllowed
+	vendor, err := route(channel, operation)
+	if err == nil {
+		err = checkBalance(vendor, channel)
 	}
-	channel, err := c.WhichChannel(template.Category)
 	if err != nil {
-		logger.E("occur error when assembleMetaData: %v\n", err)
-		return nil, nil, err
+		span.RecordError(err)
+		return nil, t.UnknownChannel, nil, err
 	}
-	vendor, err := v.GetByChannel(channel)
+	span.SetAttribute("vendor", string(vendor.Name()))
+	return template, channel, vendor, nil
+}
+
+// resolve finds template name, pinned to version other than 0, and its channel in the current config
+func resolve(span trace.Span, name string, version int64) (*t.SMSTemplate, t.Channel, error) {
+	//one snapshot for all lookups so a concurrent reload can not mix two configs
+	snapshot := c.Current()
+	template, err := snapshot.Template(t.Name(name))
+	if version != 0 {
+		template, err = snapshot.TemplateVersion(t.Name(name), version)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, t.UnknownChannel, err
+	}
+	channel, err := snapshot.Channel(template.Category)
+	if err != nil {
+		span.RecordError(err)
+		return nil, t.UnknownChannel, err
+	}
+	span.SetAttribute("config_version", snapshot.Version)
+	span.SetAttribute("template_version", template.Version())
+	span.SetAttribute("channel", channel.String())
+	return template, channel, nil
+}
+
+func assembleMetaData(ctx context.Context, contexts []*m.SMSContext) ([]*m.SMSContext, v.Vendor, error) {
+	template, channel, vendor, err := lookup(ctx, contexts[0].Template, contexts[0].TemplateVersion, v.OpSend)
 	if err != nil {
-		logger.E("occur error when assembleMetaData: %v\n", err)
+		logger.Error("occur error when assembleMetaData", logger.Template(contexts[0].Template), logger.Err(err))
 		return nil, nil, err
 	}
+	allowedContexts, decisions := middleware.NewMiddleware(template.ActionList...).Decide(ctx, contexts)
+	observeDecisions(contexts, decisions)
+	if len(allowedContexts) == 0 {
+		return nil, nil, ErrNotAllowed
+	}
+	if err = render(ctx, template, channel, vendor, allowedContexts); err != nil {
+		return nil, nil, err
+	}
+	return allowedContexts, vendor, nil
+}
 
+// render attaches histories to contexts sharing one msgid, with the content rendered from their common variables,
+// contexts of templates tracking links get a msgid each
+func render(ctx context.Context, template *t.SMSTemplate, channel t.Channel, vendor v.Vendor, allowedContexts []*m.SMSContext) error {
+	_, span := trace.Start(ctx, trace.SpanRender)
+	defer span.End()
 	// generate msgid list and contents
 	msgID := m.NewSmsContextID()
 
 	var variablesArray []string
-	for key, value := range contexts[0].Variables {
+	for key, value := range allowedContexts[0].Variables {
 		variablesArray = append(variablesArray, fmt.Sprintf(variableWrapper, key), value)
 	}
 	if len(variablesArray)%2 == 1 {
-		return nil, nil, ErrInvalidVariables
+		span.RecordError(ErrInvalidVariables)
+		return ErrInvalidVariables
 	}
 	replacer := strings.NewReplacer(variablesArray...)
-	content := replacer.Replace(template.Content)
+	logger.Debug("assembled sms", logger.Template(allowedContexts[0].Template), logger.MsgID(msgID),
+		logger.Variables(allowedContexts[0].Variables, template.Sensitive))
 
+	// each variant is rendered once, contexts without variants share the template content.
+	// tracked links tell recipients apart, so each of them gets its own msgid and content then,
+	// and sendByContent sends them as one MultiXSend batch.
+	contents := make(map[string]string)
+	perRecipient := template.TrackLinks && len(allowedContexts) > 1
 	for i := range allowedContexts {
-		contexts[i].History = &m.SMSHistory{
-			MID:       contexts[i].ID,
-			MsgID:     msgID,
-			Timestamp: time.Now(),
-			Phone:     contexts[i].Phone,
-			Content:   content,
-			Template:  contexts[i].Template,
-			Category:  string(template.Category),
-			Channel:   int(channel),
-			Vendor:    string(vendor.Name()),
-			State:     m.SMSStateUnchecked,
+		variant, raw := template.Choose(allowedContexts[i].Phone)
+		id := msgID
+		content, rendered := contents[variant]
+		if perRecipient {
+			id = m.NewSmsContextID()
+			content = trackLinks(ctx, template, variant, replacer.Replace(raw), id, allowedContexts[i].UserID)
+		} else if !rendered {
+			content = trackLinks(ctx, template, variant, replacer.Replace(raw), msgID, allowedContexts[i].UserID)
+			contents[variant] = content
+		}
+		allowedContexts[i].History = &m.SMSHistory{
+			MID:             allowedContexts[i].ID,
+			MsgID:           id,
+			Timestamp:       time.Now(),
+			Phone:           allowedContexts[i].Phone,
+			Content:         content,
+			Template:        allowedContexts[i].Template,
+			TemplateVersion: template.Version(),
+			Variant:         variant,
+			Category:        string(template.Category),
+			Channel:         int(channel),
+			Vendor:          string(vendor.Name()),
+			State:           m.SMSStateUnchecked,
 		}
 	}
-	return allowedContexts, vendor, nil
+	return nil
 }
 
-func assembleMultiMetaData(contexts []*m.SMSContext) ([]*m.SMSContext, v.Vendor, error) {
-	template, err := c.WhichTemplate(t.Name(contexts[0].Template))
+func assembleMultiMetaData(ctx context.Context, contexts []*m.SMSContext) ([]*m.SMSContext, v.Vendor, error) {
+	template, channel, vendor, err := lookup(ctx, contexts[0].Template, contexts[0].TemplateVersion, v.OpMultiXSend)
 	if err != nil {
-		logger.E("occur error when assembleMultiMetaData: %v\n", err)
+		logger.Error("occur error when assembleMultiMetaData", logger.Template(contexts[0].Template), logger.Err(err))
 		return nil, nil, err
 	}
-	allowedContexts := middleware.NewMiddleware(template.ActionList...).Call(contexts)
+	allowedContexts, decisions := middleware.NewMiddleware(template.ActionList...).Decide(ctx, contexts)
+	observeDecisions(contexts, decisions)
 	if len(allowedContexts) == 0 {
 		return nil, nil, ErrNotAllowed
 	}
-	channel, err := c.WhichChannel(template.Category)
-	if err != nil {
-		logger.E("occur error when assembleMultiMetaData: %v\n", err)
-		return nil, nil, err
-	}
-	vendor, err := v.GetByChannel(channel)
-	if err != nil {
-		logger.E("occur error when assembleMultiMetaData: %v\n", err)
+	if err = renderMulti(ctx, template, channel, vendor, allowedContexts); err != nil {
 		return nil, nil, err
 	}
+	return allowedContexts, vendor, nil
+}
 
+// renderMulti attaches histories to contexts, each with its own msgid and content rendered from its own variables
+func renderMulti(ctx context.Context, template *t.SMSTemplate, channel t.Channel, vendor v.Vendor, allowedContexts []*m.SMSContext) error {
+	_, span := trace.Start(ctx, trace.SpanRender)
+	defer span.End()
 	// generate msgid list and contents
 	msgIDList := make([]int64, len(allowedContexts))
 	contentList := make([]string, len(allowedContexts))
+	variantList := make([]string, len(allowedContexts))
 	for i := range allowedContexts {
 		msgIDList[i] = m.NewSmsContextID()
 
@@ -150,26 +256,31 @@ func assembleMultiMetaData(contexts []*m.SMSContext) ([]*m.SMSContext, v.Vendor,
 			variablesArray = append(variablesArray, fmt.Sprintf(variableWrapper, key), value)
 		}
 		if len(variablesArray)%2 == 1 {
-			return nil, nil, ErrInvalidVariables
+			span.RecordError(ErrInvalidVariables)
+			return ErrInvalidVariables
 		}
 		replacer := strings.NewReplacer(variablesArray...)
-		assembled := replacer.Replace(template.Content)
-		contentList[i] = assembled
+		variant, raw := template.Choose(allowedContexts[i].Phone)
+		assembled := replacer.Replace(raw)
+		contentList[i] = trackLinks(ctx, template, variant, assembled, msgIDList[i], allowedContexts[i].UserID)
+		variantList[i] = variant
 	}
 
 	for i := range allowedContexts {
 		allowedContexts[i].History = &m.SMSHistory{
-			MID:       allowedContexts[i].ID,
-			MsgID:     msgIDList[i],
-			Timestamp: time.Now(),
-			Phone:     allowedContexts[i].Phone,
-			Content:   contentList[i],
-			Template:  allowedContexts[i].Template,
-			Category:  string(template.Category),
-			Channel:   int(channel),
-			Vendor:    string(vendor.Name()),
-			State:     m.SMSStateUnchecked,
+			MID:             allowedContexts[i].ID,
+			MsgID:           msgIDList[i],
+			Timestamp:       time.Now(),
+			Phone:           allowedContexts[i].Phone,
+			Content:         contentList[i],
+			Template:        allowedContexts[i].Template,
+			TemplateVersion: template.Version(),
+			Variant:         variantList[i],
+			Category:        string(template.Category),
+			Channel:         int(channel),
+			Vendor:          string(vendor.Name()),
+			State:           m.SMSStateUnchecked,
 		}
 	}
-	return allowedContexts, vendor, nil
+	return nil
 }
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+
+	"github.com/linkedin-inc/mane/logger"
+	m "github.com/linkedin-inc/mane/model"
+	"github.com/linkedin-inc/mane/trace"
+	"github.com/linkedin-inc/mane/track"
+	v "github.com/linkedin-inc/mane/vendor"
+)
+
+//sendByContent sends contexts sharing a content in one request, contents differ only when the template has variants.
+//Contexts rendered per recipient carry a msgid each and go out as one MultiXSend batch, dispatched concurrently on the shared pool.
+//Variants failing to send are recorded on span, their contexts are left out of the succeed ones.
+func sendByContent(ctx context.Context, span trace.Span, vendor v.Vendor, contexts []*m.SMSContext) ([]*m.SMSContext, error) {
+	if renderedPerRecipient(contexts) {
+		return v.MultiXSendContext(ctx, vendor, contexts)
+	}
+	groups := groupByContent(contexts)
+	if len(groups) == 1 {
+		return v.SendContext(ctx, vendor, contexts)
+	}
+	var succeedContexts []*m.SMSContext
+	var lastErr error
+	failed := 0
+	for _, group := range groups {
+		succeed, err := v.SendContext(ctx, vendor, group)
+		if err != nil {
+			logger.Error("failed to send variant", logger.Template(group[0].Template), logger.Any("variant", group[0].History.Variant), logger.Err(err))
+			lastErr = err
+			failed++
+			continue
+		}
+		succeedContexts = append(succeedContexts, succeed...)
+	}
+	if len(succeedContexts) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	if lastErr != nil {
+		span.SetAttribute("failed_variants", failed)
+		span.RecordError(lastErr)
+	}
+	return succeedContexts, nil
+}
+
+//renderedPerRecipient tells whether render gave each context its own msgid, which it does for all contexts or none
+func renderedPerRecipient(contexts []*m.SMSContext) bool {
+	return len(contexts) > 1 && contexts[0].History.MsgID != contexts[1].History.MsgID
+}
+
+//groupByContent keeps the order contents first appear in
+func groupByContent(contexts []*m.SMSContext) [][]*m.SMSContext {
+	var groups [][]*m.SMSContext
+	index := make(map[string]int)
+	for _, context := range contexts {
+		i, existed := index[context.History.Content]
+		if !existed {
+			i = len(groups)
+			index[context.History.Content] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], context)
+	}
+	return groups
+}
+
+var conversion *track.Conversion
+
+//RegisterConversion makes Send and MultiXSend report every message accepted by a vendor to c,
+//register c.Callback for delivery reports and feed it clicks to complete the picture.
+func RegisterConversion(c *track.Conversion) {
+	conversion = c
+}
+
+//observeConversion is called with the contexts a vendor accepted, never with those discarded by the run mode
+func observeConversion(succeedContexts []*m.SMSContext) {
+	if conversion == nil {
+		return
+	}
+	for _, context := range succeedContexts {
+		conversion.Sent(context.History)
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	c "github.com/linkedin-inc/mane/config"
+	m "github.com/linkedin-inc/mane/model"
+	tp "github.com/linkedin-inc/mane/template"
+	"github.com/linkedin-inc/mane/track"
+	v "github.com/linkedin-inc/mane/vendor"
+)
+
+func TestSendVariants(t *testing.T) {
+	categories := []tp.SMSCategory{{Name: "variant", Channel: tp.MarketingChannel}}
+	templates := []tp.SMSTemplate{{Name: "variant", Category: "variant", Content: "hi {name}", Enabled: true,
+		Variants: []tp.Variant{{Name: "a", Content: "hello {name}", Weight: 1}, {Name: "b", Content: "hey {name}", Weight: 1}}}}
+	if _, err := c.Apply(categories, templates); err != nil {
+		t.Fatalf("TestSendVariants failed. err:%v\n", err)
+	}
+	c.SetRunMode(c.ModeSandbox)
+	defer c.SetRunMode(c.ModeDryRun)
+	conversion := track.NewConversion()
+	RegisterConversion(conversion)
+	defer RegisterConversion(nil)
+	sandbox := v.SandboxVendor()
+	sandbox.Reset()
+
+	contexts := make([]*m.SMSContext, 20)
+	for i := range contexts {
+		contexts[i] = m.NewSMSContext(int64(i), strconv.Itoa(13800000000+i), "variant", map[string]string{"name": "Li"})
+	}
+	succeed, err := Send(contexts)
+	if err != nil || len(succeed) != 20 {
+		t.Fatalf("TestSendVariants failed. succeed:%d, err:%v\n", len(succeed), err)
+	}
+	sentByVariant := make(map[string]int)
+	for _, history := range sandbox.Sent() {
+		expected := map[string]string{"a": "hello Li", "b": "hey Li"}[history.Variant]
+		if history.Content != expected {
+			t.Fatalf("TestSendVariants failed. variant:%s, content:%s\n", history.Variant, history.Content)
+		}
+		sentByVariant[history.Variant]++
+	}
+	if sentByVariant["a"] == 0 || sentByVariant["b"] == 0 {
+		t.Fatalf("TestSendVariants failed. sent:%v\n", sentByVariant)
+	}
+	//one request per variant
+	if sandbox.Requests() != 2 {
+		t.Fatalf("TestSendVariants failed. requests:%d\n", sandbox.Requests())
+	}
+	report := conversion.Report("variant")
+	if len(report) != 2 || report[0].Sent != int64(sentByVariant["a"]) || report[1].Sent != int64(sentByVariant["b"]) {
+		t.Fatalf("TestSendVariants failed. report:%v\n", report)
+	}
+	//messages discarded by the run mode are not counted as sent
+	c.SetRunMode(c.ModeDryRun)
+	if _, err = Send(contexts); err != nil {
+		t.Fatalf("TestSendVariants failed. err:%v\n", err)
+	}
+	if again := conversion.Report("variant"); again[0].Sent != report[0].Sent || again[1].Sent != report[1].Sent {
+		t.Fatalf("TestSendVariants dry run failed. report:%v\n", again)
+	}
+}
+
+func TestSendVariantClicks(t *testing.T) {
+	categories := []tp.SMSCategory{{Name: "variant", Channel: tp.MarketingChannel}}
+	templates := []tp.SMSTemplate{{Name: "click", Category: "variant", Content: "hi https://example.com/sale", Enabled: true, TrackLinks: true,
+		Variants: []tp.Variant{{Name: "a", Content: "hello https://example.com/sale", Weight: 1}, {Name: "b", Content: "hey https://example.com/sale", Weight: 1}}}}
+	if _, err := c.Apply(categories, templates); err != nil {
+		t.Fatalf("TestSendVariantClicks failed. err:%v\n", err)
+	}
+	c.SetRunMode(c.ModeSandbox)
+	defer c.SetRunMode(c.ModeDryRun)
+	conversion := track.NewConversion()
+	RegisterConversion(conversion)
+	defer RegisterConversion(nil)
+	shortener := track.NewShortener(track.NewMemoryStore(), "https://t.example.com/").OnClick(conversion.ClickHook())
+	RegisterShortener(shortener)
+	defer RegisterShortener(nil)
+	v.SandboxVendor().Reset()
+
+	contexts := make([]*m.SMSContext, 10)
+	for i := range contexts {
+		contexts[i] = m.NewSMSContext(int64(i), strconv.Itoa(13800000000+i), "click", nil)
+		contexts[i].UserID = int64(i + 1)
+	}
+	succeed, err := Send(contexts)
+	if err != nil || len(succeed) != 10 {
+		t.Fatalf("TestSendVariantClicks failed. succeed:%d, err:%v\n", len(succeed), err)
+	}
+	//every recipient clicks, the first one twice
+	for i, context := range append(contexts, contexts[0]) {
+		shortURL := strings.Fields(context.History.Content)[1]
+		if i < 10 {
+			conversion.Delivered(&m.DeliveryStatus{MsgID: context.History.MsgID}, context.History)
+		}
+		shortener.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", shortURL, nil))
+	}
+	var clicked int64
+	for _, stats := range conversion.Report("click") {
+		if stats.Clicked != stats.Sent || stats.ClickRate() != 1 {
+			t.Fatalf("TestSendVariantClicks failed. stats:%+v\n", stats)
+		}
+		clicked += stats.Clicked
+	}
+	if clicked != 10 {
+		t.Fatalf("TestSendVariantClicks failed. clicked:%d\n", clicked)
+	}
+}
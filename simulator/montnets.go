@@ -0,0 +1,251 @@
+package simulator
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/axgle/mahonia"
+)
+
+const (
+	montnetsSendPath       = "/MWGate/wmgw.asmx/MongateCsSpSendSmsNew"
+	montnetsMultiXSendPath = "/MWGate/wmgw.asmx/MongateMULTIXSend"
+	montnetsUpstreamPath   = "/MWGate/wmgw.asmx/MongateGetDeliver"
+	montnetsBalancePath    = "/MWGate/wmgw.asmx/MongateQueryBalance"
+	montnetsNamespace      = "http://tempuri.org/"
+	montnetsTimeLayout     = "2006-01-02 15:04:05"
+	montnetsMaxPhones      = 100
+	montnetsMaxRecords     = 500
+)
+
+//Message is a message accepted by a simulator
+type Message struct {
+	MsgID   string
+	Phone   string
+	Content string
+}
+
+//Montnets speaks the wire format of Montnets: XML <string> answers to sends, multixmt payloads
+//of GBK content in base64, and comma separated delivery and reply records.
+type Montnets struct {
+	*httptest.Server
+	Username string
+	Password string
+
+	locker   sync.Mutex
+	failures []string
+	balance  int64
+	sent     []Message
+	records  map[string][]string
+}
+
+func NewMontnets(username, password string) *Montnets {
+	s := &Montnets{
+		Username: username,
+		Password: password,
+		balance:  10000,
+		records:  make(map[string][]string),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(montnetsSendPath, s.handleSend)
+	mux.HandleFunc(montnetsMultiXSendPath, s.handleMultiXSend)
+	mux.HandleFunc(montnetsUpstreamPath, s.handleUpstream)
+	mux.HandleFunc(montnetsBalancePath, s.handleBalance)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+func (s *Montnets) SendEndpoint() string {
+	return s.URL + montnetsSendPath
+}
+
+func (s *Montnets) MultiXSendEndpoint() string {
+	return s.URL + montnetsMultiXSendPath
+}
+
+//StatusEndpoint serves both delivery reports and replies, told apart by iReqType
+func (s *Montnets) StatusEndpoint() string {
+	return s.URL + montnetsUpstreamPath
+}
+
+func (s *Montnets) BalanceEndpoint() string {
+	return s.URL + montnetsBalancePath
+}
+
+//Fail makes the next sends answer with given error codes in turn, e.g. "-999" or "-10056"
+func (s *Montnets) Fail(codes ...string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.failures = append(s.failures, codes...)
+}
+
+func (s *Montnets) SetBalance(balance int64) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.balance = balance
+}
+
+//Deliver reports every message sent so far to phone with statusCode, 0 means delivered
+func (s *Montnets) Deliver(phone string, statusCode int) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	now := time.Now().Format(montnetsTimeLayout)
+	detail := "DELIVRD"
+	if statusCode != 0 {
+		detail = "MK:" + strconv.Itoa(statusCode)
+	}
+	for _, message := range s.sent {
+		if message.Phone != phone {
+			continue
+		}
+		record := strings.Join([]string{"2", now, message.MsgID, "*", phone, message.MsgID, "*", strconv.Itoa(statusCode), detail}, ",")
+		s.records["2"] = append(s.records["2"], record)
+	}
+}
+
+//AddReply makes phone reply with text
+func (s *Montnets) AddReply(phone, text string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	now := time.Now().Format(montnetsTimeLayout)
+	record := strings.Join([]string{"1", now, phone, "10690000", "*", "*", text}, ",")
+	s.records["1"] = append(s.records["1"], record)
+}
+
+//Sent returns every message accepted so far
+func (s *Montnets) Sent() []Message {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return append([]Message(nil), s.sent...)
+}
+
+func (s *Montnets) authorized(r *http.Request) bool {
+	return r.Form.Get("userId") == s.Username && r.Form.Get("password") == s.Password
+}
+
+//nextFailure pops the next scripted failure, the caller must hold the lock
+func (s *Montnets) nextFailure() string {
+	if len(s.failures) == 0 {
+		return ""
+	}
+	code := s.failures[0]
+	s.failures = s.failures[1:]
+	return code
+}
+
+func (s *Montnets) handleSend(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	phones := strings.Split(r.Form.Get("pszMobis"), ",")
+	switch {
+	case !s.authorized(r):
+		writeXML(w, "string", "-10001")
+		return
+	case r.Form.Get("pszMobis") == "" || r.Form.Get("pszMsg") == "":
+		writeXML(w, "string", "-1")
+		return
+	case len(phones) > montnetsMaxPhones:
+		writeXML(w, "string", "-14")
+		return
+	case r.Form.Get("iMobiCount") != strconv.Itoa(len(phones)):
+		writeXML(w, "string", "-12")
+		return
+	}
+	if code := s.nextFailure(); code != "" {
+		writeXML(w, "string", code)
+		return
+	}
+	msgID := r.Form.Get("MsgId")
+	for _, phone := range phones {
+		s.sent = append(s.sent, Message{MsgID: msgID, Phone: phone, Content: r.Form.Get("pszMsg")})
+	}
+	writeXML(w, "string", msgID)
+}
+
+func (s *Montnets) handleMultiXSend(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if !s.authorized(r) {
+		writeXML(w, "string", "-10001")
+		return
+	}
+	items := strings.Split(r.Form.Get("multixmt"), ",")
+	if len(items) > montnetsMaxPhones {
+		writeXML(w, "string", "-14")
+		return
+	}
+	var messages []Message
+	decoder := mahonia.NewDecoder("GBK")
+	for _, item := range items {
+		//msgid|subport|phone|base64 of GBK content
+		fields := strings.Split(item, "|")
+		if len(fields) != 4 {
+			writeXML(w, "string", "-1")
+			return
+		}
+		content, err := base64.StdEncoding.DecodeString(fields[3])
+		if err != nil {
+			writeXML(w, "string", "-1")
+			return
+		}
+		messages = append(messages, Message{MsgID: fields[0], Phone: fields[2], Content: decoder.ConvertString(string(content))})
+	}
+	if code := s.nextFailure(); code != "" {
+		writeXML(w, "string", code)
+		return
+	}
+	s.sent = append(s.sent, messages...)
+	writeXML(w, "string", messages[0].MsgID)
+}
+
+func (s *Montnets) handleUpstream(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if !s.authorized(r) {
+		writeXML(w, "string", "-10001")
+		return
+	}
+	requestType := r.Form.Get("iReqType")
+	records := s.records[requestType]
+	if len(records) > montnetsMaxRecords {
+		records = records[:montnetsMaxRecords]
+	}
+	s.records[requestType] = s.records[requestType][len(records):]
+	var body strings.Builder
+	body.WriteString(xml.Header)
+	body.WriteString(`<ArrayOfString xmlns="` + montnetsNamespace + `">`)
+	for _, record := range records {
+		body.WriteString("<string>")
+		_ = xml.EscapeText(&body, []byte(record))
+		body.WriteString("</string>")
+	}
+	body.WriteString("</ArrayOfString>")
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	_, _ = w.Write([]byte(body.String()))
+}
+
+func (s *Montnets) handleBalance(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if !s.authorized(r) {
+		writeXML(w, "int", "-10001")
+		return
+	}
+	writeXML(w, "int", strconv.FormatInt(s.balance, 10))
+}
+
+func writeXML(w http.ResponseWriter, element, value string) {
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	_, _ = fmt.Fprintf(w, `%s<%s xmlns="%s">%s</%s>`, xml.Header, element, montnetsNamespace, value, element)
+}
@@ -0,0 +1,233 @@
+package simulator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	yunpianSendPath      = "/v1/sms/send.json"
+	yunpianMultiSendPath = "/v1/sms/multi_send.json"
+	yunpianStatusPath    = "/v1/sms/pull_status.json"
+	yunpianReplyPath     = "/v1/sms/pull_reply.json"
+	yunpianUserPath      = "/v1/user/get.json"
+	yunpianTimeLayout    = "2006-01-02 15:04:05"
+	yunpianDefaultPage   = 20
+	yunpianMaxPage       = 100
+)
+
+//codes answered by Yunpian
+const (
+	YunpianOK            = 0
+	YunpianInvalidParams = 2
+	YunpianInvalidAPIKey = -1
+)
+
+//Yunpian speaks the JSON wire format of Yunpian
+type Yunpian struct {
+	*httptest.Server
+	APIKey string
+	//Balance is what the account has left in CNY
+	Balance float64
+
+	locker   sync.Mutex
+	failures []int
+	sent     []Message
+	statuses []map[string]interface{}
+	replies  []map[string]interface{}
+}
+
+func NewYunpian(apiKey string) *Yunpian {
+	s := &Yunpian{APIKey: apiKey}
+	mux := http.NewServeMux()
+	mux.HandleFunc(yunpianSendPath, s.handleSend)
+	mux.HandleFunc(yunpianMultiSendPath, s.handleMultiSend)
+	mux.HandleFunc(yunpianStatusPath, s.handleStatus)
+	mux.HandleFunc(yunpianReplyPath, s.handleReply)
+	mux.HandleFunc(yunpianUserPath, s.handleUser)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+func (s *Yunpian) SendEndpoint() string {
+	return s.URL + yunpianSendPath
+}
+
+func (s *Yunpian) MultiSendEndpoint() string {
+	return s.URL + yunpianMultiSendPath
+}
+
+func (s *Yunpian) StatusEndpoint() string {
+	return s.URL + yunpianStatusPath
+}
+
+func (s *Yunpian) ReplyEndpoint() string {
+	return s.URL + yunpianReplyPath
+}
+
+func (s *Yunpian) BalanceEndpoint() string {
+	return s.URL + yunpianUserPath
+}
+
+//Fail makes the next sends answer with given codes in turn
+func (s *Yunpian) Fail(codes ...int) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.failures = append(s.failures, codes...)
+}
+
+//Deliver reports every message sent so far to phone, delivered or not
+func (s *Yunpian) Deliver(phone string, delivered bool) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	now := time.Now().Format(yunpianTimeLayout)
+	report, errorMsg := "SUCCESS", "DELIVRD"
+	if !delivered {
+		report, errorMsg = "FAIL", "UNDELIV"
+	}
+	for _, message := range s.sent {
+		if message.Phone != phone {
+			continue
+		}
+		s.statuses = append(s.statuses, map[string]interface{}{
+			"sid":               len(s.statuses) + 1,
+			"uid":               message.MsgID,
+			"user_receive_time": now,
+			"error_msg":         errorMsg,
+			"mobile":            phone,
+			"report_status":     report,
+		})
+	}
+}
+
+//AddReply makes phone reply with text
+func (s *Yunpian) AddReply(phone, text string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.replies = append(s.replies, map[string]interface{}{
+		"mobile":      phone,
+		"reply_time":  time.Now().Format(yunpianTimeLayout),
+		"text":        text,
+		"extend":      "",
+		"base_extend": "8888",
+	})
+}
+
+//Sent returns every message accepted so far
+func (s *Yunpian) Sent() []Message {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return append([]Message(nil), s.sent...)
+}
+
+func (s *Yunpian) handleSend(w http.ResponseWriter, r *http.Request) {
+	s.handleMessages(w, r, func(phones []string, text string) []string {
+		//one text to every phone
+		texts := make([]string, len(phones))
+		for i := range texts {
+			texts[i] = text
+		}
+		return texts
+	})
+}
+
+func (s *Yunpian) handleMultiSend(w http.ResponseWriter, r *http.Request) {
+	s.handleMessages(w, r, func(phones []string, text string) []string {
+		texts := strings.Split(text, ",")
+		if len(texts) != len(phones) {
+			return nil
+		}
+		return texts
+	})
+}
+
+func (s *Yunpian) handleMessages(w http.ResponseWriter, r *http.Request, split func(phones []string, text string) []string) {
+	_ = r.ParseForm()
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if r.Form.Get("apikey") != s.APIKey {
+		writeJSON(w, YunpianInvalidAPIKey, "非法的apikey", nil)
+		return
+	}
+	if r.Form.Get("mobile") == "" || r.Form.Get("text") == "" {
+		writeJSON(w, YunpianInvalidParams, "参数格式不正确", nil)
+		return
+	}
+	phones := strings.Split(r.Form.Get("mobile"), ",")
+	texts := split(phones, r.Form.Get("text"))
+	if texts == nil {
+		writeJSON(w, YunpianInvalidParams, "手机号和内容个数不匹配", nil)
+		return
+	}
+	if len(s.failures) > 0 {
+		code := s.failures[0]
+		s.failures = s.failures[1:]
+		writeJSON(w, code, "发送失败", nil)
+		return
+	}
+	msgID := r.Form.Get("uid")
+	for i, phone := range phones {
+		s.sent = append(s.sent, Message{MsgID: msgID, Phone: phone, Content: texts[i]})
+	}
+	writeJSON(w, YunpianOK, "OK", map[string]interface{}{"result": strconv.Itoa(len(phones))})
+}
+
+func (s *Yunpian) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.handlePull(w, r, &s.statuses, "msg_status")
+}
+
+func (s *Yunpian) handleReply(w http.ResponseWriter, r *http.Request) {
+	s.handlePull(w, r, &s.replies, "sms_reply")
+}
+
+func (s *Yunpian) handleUser(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if r.Form.Get("apikey") != s.APIKey {
+		writeJSON(w, YunpianInvalidAPIKey, "非法的apikey", nil)
+		return
+	}
+	writeJSON(w, YunpianOK, "OK", map[string]interface{}{"user": map[string]interface{}{"nick": "mane", "balance": s.Balance}})
+}
+
+//handlePull serves and forgets a page of pending records
+func (s *Yunpian) handlePull(w http.ResponseWriter, r *http.Request, pending *[]map[string]interface{}, key string) {
+	_ = r.ParseForm()
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if r.Form.Get("apikey") != s.APIKey {
+		writeJSON(w, YunpianInvalidAPIKey, "非法的apikey", nil)
+		return
+	}
+	pageSize, err := strconv.Atoi(r.Form.Get("page_size"))
+	if err != nil || pageSize <= 0 {
+		pageSize = yunpianDefaultPage
+	}
+	if pageSize > yunpianMaxPage {
+		pageSize = yunpianMaxPage
+	}
+	page := *pending
+	if len(page) > pageSize {
+		page = page[:pageSize]
+	}
+	*pending = (*pending)[len(page):]
+	if page == nil {
+		page = []map[string]interface{}{}
+	}
+	writeJSON(w, YunpianOK, "OK", map[string]interface{}{key: page})
+}
+
+func writeJSON(w http.ResponseWriter, code int, msg string, extra map[string]interface{}) {
+	body := map[string]interface{}{"code": code, "msg": msg}
+	for key, value := range extra {
+		body[key] = value
+	}
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	_ = json.NewEncoder(w).Encode(body)
+}
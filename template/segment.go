@@ -0,0 +1,44 @@
+package template
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	gsm7Single = 160
+	gsm7Part   = 153
+	ucs2Single = 70
+	ucs2Part   = 67
+	//gsm7Basic is the GSM 03.38 default alphabet, every character takes one septet
+	gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	//gsm7Extended characters take an escape septet and their own
+	gsm7Extended = "^{}\\[~]|€\f"
+)
+
+//Segments returns how many messages content is split into and billed as, 0 for empty content.
+//Content fitting the GSM alphabet takes 160 characters or 153 per part, anything else, e.g. chinese, 70 or 67 per part.
+func Segments(content string) int {
+	if content == "" {
+		return 0
+	}
+	septets := 0
+	for _, r := range content {
+		switch {
+		case strings.ContainsRune(gsm7Basic, r):
+			septets++
+		case strings.ContainsRune(gsm7Extended, r):
+			septets += 2
+		default:
+			return split(len(utf16.Encode([]rune(content))), ucs2Single, ucs2Part)
+		}
+	}
+	return split(septets, gsm7Single, gsm7Part)
+}
+
+func split(length, single, part int) int {
+	if length <= single {
+		return 1
+	}
+	return (length + part - 1) / part
+}
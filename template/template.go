@@ -1,6 +1,7 @@
 package template
 
 import (
+	"hash/fnv"
 	"sync"
 
 	c "github.com/linkedin-inc/mane/callback"
@@ -79,9 +80,19 @@ type SMSTemplate struct {
 	Description      string                    `bson:"description" json:"description"`
 	Callback         c.Name                    `bson:"callback" json:"callback"`
 	ActionStructList []middleware.ActionStruct `bson:"actions" json:"actions"`
+	Sensitive        []string                  `bson:"sensitive" json:"sensitive"`     //variables never written to logs, e.g. verification codes
+	Variants         []Variant                 `bson:"variants" json:"variants"`       //wordings compared against each other, Content is used when empty
+	TrackLinks       bool                      `bson:"track_links" json:"track_links"` //add tracking params to URLs in rendered content
 	ActionList       []middleware.Action       `bson:"-" json:"-"`
 }
 
+//Variant is a wording of a template, phones are assigned to variants in proportion to their weights
+type Variant struct {
+	Name    string `bson:"name" json:"name"`
+	Content string `bson:"content" json:"content"`
+	Weight  int    `bson:"weight" json:"weight"`
+}
+
 var ActionCenter = make(map[string]middleware.Action)
 var locker = new(sync.RWMutex)
 
@@ -91,6 +102,33 @@ func RegisterAction(actionName string, action middleware.Action) {
 	if _, ok := ActionCenter[actionName]; ok {
 		panic("sms duplicate action registered: " + actionName)
 	}
-	logger.I("%v registered\n", actionName)
+	logger.Info("action registered", logger.Any("action", actionName))
 	ActionCenter[actionName] = action
 }
+
+//Version identifies a revision of the template, it is the timestamp the template was published with
+func (template SMSTemplate) Version() int64 {
+	return template.Timestamp
+}
+
+//Choose assigns phone to a variant, the same phone always gets the same variant as long as the variants do not change.
+//It returns an empty name and Content when the template has no variants.
+func (template SMSTemplate) Choose(phone string) (string, string) {
+	total := 0
+	for _, variant := range template.Variants {
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return "", template.Content
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(string(template.Name) + ":" + phone))
+	point := int(hash.Sum32() % uint32(total))
+	for _, variant := range template.Variants {
+		if point < variant.Weight {
+			return variant.Name, variant.Content
+		}
+		point -= variant.Weight
+	}
+	return "", template.Content
+}
@@ -0,0 +1,61 @@
+package template
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSMSTemplate_Choose(t *testing.T) {
+	template := SMSTemplate{
+		Name:    "sale",
+		Content: "default",
+		Variants: []Variant{
+			{Name: "a", Content: "wording a", Weight: 3},
+			{Name: "b", Content: "wording b", Weight: 1},
+		},
+	}
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		phone := strconv.Itoa(13800000000 + i)
+		variant, content := template.Choose(phone)
+		if again, _ := template.Choose(phone); again != variant {
+			t.Fatalf("TestSMSTemplate_Choose failed. %s got %s then %s\n", phone, variant, again)
+		}
+		if content != "wording "+variant {
+			t.Fatalf("TestSMSTemplate_Choose failed. variant:%s, content:%s\n", variant, content)
+		}
+		counts[variant]++
+	}
+	if counts["a"] < 2800 || counts["a"] > 3200 || counts["a"]+counts["b"] != 4000 {
+		t.Fatalf("TestSMSTemplate_Choose failed. counts:%v\n", counts)
+	}
+
+	template.Variants = nil
+	if variant, content := template.Choose("13800000000"); variant != "" || content != "default" {
+		t.Fatalf("TestSMSTemplate_Choose failed. variant:%s, content:%s\n", variant, content)
+	}
+}
+
+func TestSegments(t *testing.T) {
+	cases := map[string]int{
+		"":                       0,
+		"hello":                  1,
+		strings.Repeat("a", 160): 1,
+		strings.Repeat("a", 161): 2,
+		strings.Repeat("{", 80):  1,
+		strings.Repeat("{", 81):  2,
+		strings.Repeat("验", 70):  1,
+		strings.Repeat("验", 71):  2,
+		strings.Repeat("验", 134): 2,
+		strings.Repeat("a", 300): 2,
+		"code 1234 " + "验证码":     1,
+		strings.Repeat("😀", 35):  1,
+		strings.Repeat("😀", 36):  2,
+	}
+	for content, expected := range cases {
+		if segments := Segments(content); segments != expected {
+			t.Fatalf("TestSegments failed. content:%s, segments:%d, expected:%d\n", content, segments, expected)
+		}
+	}
+}
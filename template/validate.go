@@ -0,0 +1,110 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/linkedin-inc/mane/middleware"
+)
+
+var (
+	ErrEmptyName          = errors.New("name is empty")
+	ErrEmptyCategory      = errors.New("category is empty")
+	ErrEmptyContent       = errors.New("content is empty")
+	ErrUnknownChannel     = errors.New("unknown channel")
+	ErrUnknownAction      = errors.New("unknown action")
+	ErrMalformedVariables = errors.New("malformed variable placeholder")
+	ErrInvalidVariant     = errors.New("invalid variant")
+)
+
+//Validate checks the fields every category must have
+func (category SMSCategory) Validate() error {
+	if category.Name == "" {
+		return fmt.Errorf("category: %w", ErrEmptyName)
+	}
+	switch category.Channel {
+	case MarketingChannel, ProductionChannel, InternalChannel:
+		return nil
+	default:
+		return fmt.Errorf("category %s: %w %d", category.Name, ErrUnknownChannel, category.Channel)
+	}
+}
+
+//Validate checks the fields every template must have, that placeholders in content are well formed
+//and that every action is registered.
+func (template SMSTemplate) Validate() error {
+	if template.Name == "" {
+		return fmt.Errorf("template: %w", ErrEmptyName)
+	}
+	if template.Category == "" {
+		return fmt.Errorf("template %s: %w", template.Name, ErrEmptyCategory)
+	}
+	if strings.TrimSpace(template.Content) == "" {
+		return fmt.Errorf("template %s: %w", template.Name, ErrEmptyContent)
+	}
+	if err := checkPlaceholders(template.Content); err != nil {
+		return fmt.Errorf("template %s: %w", template.Name, err)
+	}
+	names := make(map[string]bool, len(template.Variants))
+	for _, variant := range template.Variants {
+		if variant.Name == "" || names[variant.Name] || variant.Weight <= 0 || strings.TrimSpace(variant.Content) == "" {
+			return fmt.Errorf("template %s: %w %q, names must be unique and weights positive", template.Name, ErrInvalidVariant, variant.Name)
+		}
+		names[variant.Name] = true
+		if err := checkPlaceholders(variant.Content); err != nil {
+			return fmt.Errorf("template %s variant %s: %w", template.Name, variant.Name, err)
+		}
+	}
+	locker.RLock()
+	defer locker.RUnlock()
+	for _, actionStruct := range template.ActionStructList {
+		if _, existed := ActionCenter[actionStruct.Name]; !existed {
+			return fmt.Errorf("template %s: %w %s", template.Name, ErrUnknownAction, actionStruct.Name)
+		}
+	}
+	return nil
+}
+
+//placeholders look like {name}, they can not be empty or nested
+func checkPlaceholders(content string) error {
+	open := -1
+	for i, r := range content {
+		switch r {
+		case '{':
+			if open >= 0 {
+				return ErrMalformedVariables
+			}
+			open = i
+		case '}':
+			if open < 0 || i == open+1 {
+				return ErrMalformedVariables
+			}
+			open = -1
+		}
+	}
+	if open >= 0 {
+		return ErrMalformedVariables
+	}
+	return nil
+}
+
+//ResolveActions builds ActionList from ActionStructList with the actions registered in ActionCenter
+func (template *SMSTemplate) ResolveActions() error {
+	locker.RLock()
+	defer locker.RUnlock()
+	actions := make([]middleware.Action, 0, len(template.ActionStructList))
+	for _, actionStruct := range template.ActionStructList {
+		registered, existed := ActionCenter[actionStruct.Name]
+		if !existed {
+			return fmt.Errorf("template %s: %w %s", template.Name, ErrUnknownAction, actionStruct.Name)
+		}
+		action, err := registered.Unmarshal(actionStruct)
+		if err != nil {
+			return fmt.Errorf("template %s: action %s: %w", template.Name, actionStruct.Name, err)
+		}
+		actions = append(actions, action)
+	}
+	template.ActionList = actions
+	return nil
+}
@@ -0,0 +1,115 @@
+package trace
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//RecordedSpan is a span kept by Recorder
+type RecordedSpan struct {
+	ID         int64
+	ParentID   int64
+	Name       string
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes map[string]interface{}
+	Errors     []error
+
+	recorder *Recorder
+}
+
+func (s *RecordedSpan) SetAttribute(key string, value interface{}) {
+	s.recorder.locker.Lock()
+	defer s.recorder.locker.Unlock()
+	s.Attributes[key] = value
+}
+
+func (s *RecordedSpan) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.recorder.locker.Lock()
+	defer s.recorder.locker.Unlock()
+	s.Errors = append(s.Errors, err)
+}
+
+func (s *RecordedSpan) End() {
+	s.recorder.locker.Lock()
+	defer s.recorder.locker.Unlock()
+	if s.EndTime.IsZero() {
+		s.EndTime = time.Now()
+	}
+}
+
+func (s *RecordedSpan) Duration() time.Duration {
+	return s.EndTime.Sub(s.StartTime)
+}
+
+type spanKey struct{}
+
+//Recorder keeps every span in memory, it is meant for tests and debugging
+type Recorder struct {
+	locker sync.Mutex
+	spans  []*RecordedSpan
+	nextID int64
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	r.nextID++
+	span := &RecordedSpan{
+		ID:         r.nextID,
+		Name:       name,
+		StartTime:  time.Now(),
+		Attributes: make(map[string]interface{}),
+		recorder:   r,
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*RecordedSpan); ok {
+		span.ParentID = parent.ID
+	}
+	r.spans = append(r.spans, span)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+//Spans returns a copy of all spans recorded so far in the order they started
+func (r *Recorder) Spans() []RecordedSpan {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	spans := make([]RecordedSpan, len(r.spans))
+	for i, span := range r.spans {
+		spans[i] = *span
+		spans[i].Attributes = make(map[string]interface{}, len(span.Attributes))
+		for k, v := range span.Attributes {
+			spans[i].Attributes[k] = v
+		}
+		spans[i].Errors = append([]error(nil), span.Errors...)
+		spans[i].recorder = nil
+	}
+	return spans
+}
+
+//Named returns the recorded spans with given name
+func (r *Recorder) Named(name string) []RecordedSpan {
+	var named []RecordedSpan
+	for _, span := range r.Spans() {
+		if span.Name == name {
+			named = append(named, span)
+		}
+	}
+	return named
+}
+
+func (r *Recorder) Reset() {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	r.spans = nil
+}
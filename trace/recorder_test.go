@@ -0,0 +1,44 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder()
+	SetTracer(recorder)
+	defer SetTracer(Noop{})
+
+	ctx, parent := Start(context.Background(), SpanSend)
+	_, child := Start(ctx, SpanRender)
+	child.SetAttribute("count", 2)
+	child.RecordError(errors.New("boom"))
+	child.End()
+	parent.End()
+
+	spans := recorder.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("TestRecorder failed. spans:%d\n", len(spans))
+	}
+	if spans[0].Name != SpanSend || spans[0].ParentID != 0 || spans[0].EndTime.IsZero() {
+		t.Errorf("TestRecorder parent failed. span:%+v\n", spans[0])
+	}
+	if spans[1].ParentID != spans[0].ID || spans[1].Attributes["count"] != 2 || len(spans[1].Errors) != 1 {
+		t.Errorf("TestRecorder child failed. span:%+v\n", spans[1])
+	}
+	if len(recorder.Named(SpanRender)) != 1 {
+		t.Error("TestRecorder Named failed")
+	}
+}
+
+func TestNoop(t *testing.T) {
+	ctx := context.Background()
+	started, span := Noop{}.Start(ctx, SpanSend)
+	span.SetAttribute("k", "v")
+	span.End()
+	if started != ctx {
+		t.Error("TestNoop failed")
+	}
+}
@@ -0,0 +1,69 @@
+package trace
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+//Span is a timed operation within a trace
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+//Tracer creates spans, implement it to bridge mane into your tracing system
+type Tracer interface {
+	//Start a span named name as a child of the span carried by ctx, the returned context carries the new span
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+//span names used by mane
+const (
+	SpanSend        = "mane.send"
+	SpanMultiXSend  = "mane.multix_send"
+	SpanConfig      = "mane.config"
+	SpanMiddleware  = "mane.middleware"
+	SpanRender      = "mane.render"
+	SpanVendorChunk = "mane.vendor.chunk"
+	SpanVendorTry   = "mane.vendor.attempt"
+	SpanPull        = "mane.pull"
+	SpanPullStatus  = "mane.pull.status"
+	SpanPullReply   = "mane.pull.reply"
+	SpanPreview     = "mane.preview"
+)
+
+type holder struct {
+	Tracer
+}
+
+var current atomic.Value
+
+func init() {
+	SetTracer(Noop{})
+}
+
+//SetTracer replaces the tracer used by mane, it is safe to call at any time
+func SetTracer(tracer Tracer) {
+	current.Store(holder{tracer})
+}
+
+//Start a span with the registered tracer
+func Start(ctx context.Context, name string) (context.Context, Span) {
+	return current.Load().(holder).Start(ctx, name)
+}
+
+//Noop discards every span, it is the default tracer
+type Noop struct{}
+
+func (Noop) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+
+func (noopSpan) RecordError(err error) {}
+
+func (noopSpan) End() {}
@@ -0,0 +1,165 @@
+package track
+
+import (
+	"sort"
+	"sync"
+
+	c "github.com/linkedin-inc/mane/callback"
+	m "github.com/linkedin-inc/mane/model"
+)
+
+//VariantStats counts how far messages of a template variant went
+type VariantStats struct {
+	Template  string
+	Variant   string
+	Sent      int64
+	Delivered int64
+	Failed    int64
+	Clicked   int64
+}
+
+//DeliveryRate is delivered over sent
+func (s VariantStats) DeliveryRate() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Delivered) / float64(s.Sent)
+}
+
+//ClickRate is clicked over delivered, the conversion compared between variants
+func (s VariantStats) ClickRate() float64 {
+	if s.Delivered == 0 {
+		return 0
+	}
+	return float64(s.Clicked) / float64(s.Delivered)
+}
+
+type variantKey struct {
+	template string
+	variant  string
+}
+
+//DefaultClickedLimit is how many clicked links a Conversion remembers to count repeated clicks once
+const DefaultClickedLimit = 1 << 20
+
+//Conversion joins sends, delivery reports and clicks of template variants,
+//messages without variant are ignored.
+type Conversion struct {
+	locker  sync.Mutex
+	stats   map[variantKey]*VariantStats
+	clicked map[TrackableLink]bool
+	//clicked links in the order they were first clicked, a ring once limit is reached
+	order []TrackableLink
+	next  int
+	limit int
+}
+
+func NewConversion() *Conversion {
+	return &Conversion{
+		stats:   make(map[variantKey]*VariantStats),
+		clicked: make(map[TrackableLink]bool),
+		limit:   DefaultClickedLimit,
+	}
+}
+
+//ClickedLimit bounds how many clicked links are remembered, the earliest clicked is forgotten first
+//and a later click on it counts again. Call it before counting clicks.
+func (cv *Conversion) ClickedLimit(limit int) *Conversion {
+	if limit > 0 {
+		cv.limit = limit
+	}
+	return cv
+}
+
+//statsOf returns the stats of a variant, the caller must hold the lock
+func (cv *Conversion) statsOf(template, variant string) *VariantStats {
+	key := variantKey{template: template, variant: variant}
+	stats, existed := cv.stats[key]
+	if !existed {
+		stats = &VariantStats{Template: template, Variant: variant}
+		cv.stats[key] = stats
+	}
+	return stats
+}
+
+//Sent counts a message accepted by the vendor
+func (cv *Conversion) Sent(history *m.SMSHistory) {
+	if history == nil || history.Variant == "" {
+		return
+	}
+	cv.locker.Lock()
+	defer cv.locker.Unlock()
+	cv.statsOf(history.Template, history.Variant).Sent++
+}
+
+//Delivered counts the delivery report of a message, it has the signature of a callback
+func (cv *Conversion) Delivered(status *m.DeliveryStatus, history *m.SMSHistory) error {
+	if history == nil || history.Variant == "" {
+		return nil
+	}
+	cv.locker.Lock()
+	defer cv.locker.Unlock()
+	stats := cv.statsOf(history.Template, history.Variant)
+	if status.StatusCode == 0 {
+		stats.Delivered++
+	} else {
+		stats.Failed++
+	}
+	return nil
+}
+
+//Callback returns Delivered as a callback to register for templates under test
+func (cv *Conversion) Callback() c.Callback {
+	return cv.Delivered
+}
+
+//Clicked counts a click on a link, repeated clicks on the same link count once while it is remembered.
+//Send renders tracked links per recipient, so links of different recipients never collapse.
+func (cv *Conversion) Clicked(link TrackableLink) {
+	if link.Variant == "" {
+		return
+	}
+	link.Append, link.Token = false, ""
+	cv.locker.Lock()
+	defer cv.locker.Unlock()
+	if cv.clicked[link] {
+		return
+	}
+	cv.remember(link)
+	cv.statsOf(link.Template, link.Variant).Clicked++
+}
+
+//remember adds link to the clicked ones, forgetting the earliest once limit is reached. The caller must hold the lock
+func (cv *Conversion) remember(link TrackableLink) {
+	cv.clicked[link] = true
+	if len(cv.order) < cv.limit {
+		cv.order = append(cv.order, link)
+		return
+	}
+	delete(cv.clicked, cv.order[cv.next])
+	cv.order[cv.next] = link
+	cv.next = (cv.next + 1) % cv.limit
+}
+
+//Report returns the stats of every variant of template seen so far, ordered by variant
+func (cv *Conversion) Report(template string) []VariantStats {
+	cv.locker.Lock()
+	defer cv.locker.Unlock()
+	var report []VariantStats
+	for key, stats := range cv.stats {
+		if key.template == template {
+			report = append(report, *stats)
+		}
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Variant < report[j].Variant
+	})
+	return report
+}
+
+//ClickHook returns a hook counting clicks on short links, pass it to Shortener.OnClick
+func (cv *Conversion) ClickHook() func(click *Click) {
+	return func(click *Click) {
+		cv.Clicked(TrackableLink{TrackID: click.TrackID, UserID: click.UserID, Template: click.Template, Variant: click.Variant})
+	}
+}
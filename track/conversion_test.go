@@ -0,0 +1,88 @@
+package track
+
+import (
+	"testing"
+
+	m "github.com/linkedin-inc/mane/model"
+)
+
+func TestConversion(t *testing.T) {
+	conversion := NewConversion()
+	histories := []*m.SMSHistory{
+		{Template: "sale", Variant: "a", Phone: "1"},
+		{Template: "sale", Variant: "a", Phone: "2"},
+		{Template: "sale", Variant: "b", Phone: "3"},
+		{Template: "sale", Phone: "4"},
+	}
+	for _, history := range histories {
+		conversion.Sent(history)
+	}
+	_ = conversion.Delivered(&m.DeliveryStatus{StatusCode: 0}, histories[0])
+	_ = conversion.Delivered(&m.DeliveryStatus{StatusCode: 1}, histories[1])
+	callback := conversion.Callback()
+	_ = callback(&m.DeliveryStatus{StatusCode: 0}, histories[2])
+	_ = callback(&m.DeliveryStatus{StatusCode: 0}, histories[3])
+
+	link := TrackableLink{TrackID: "t", UserID: 1, Template: "sale", Variant: "a"}
+	conversion.Clicked(link)
+	link.Append = true
+	conversion.Clicked(link)
+	conversion.Clicked(TrackableLink{TrackID: "t", UserID: 1})
+
+	report := conversion.Report("sale")
+	if len(report) != 2 {
+		t.Fatalf("TestConversion failed. report:%v\n", report)
+	}
+	a, b := report[0], report[1]
+	if a.Variant != "a" || a.Sent != 2 || a.Delivered != 1 || a.Failed != 1 || a.Clicked != 1 || a.ClickRate() != 1 || a.DeliveryRate() != 0.5 {
+		t.Fatalf("TestConversion failed. a:%+v\n", a)
+	}
+	if b.Variant != "b" || b.Sent != 1 || b.Delivered != 1 || b.Clicked != 0 || b.ClickRate() != 0 {
+		t.Fatalf("TestConversion failed. b:%+v\n", b)
+	}
+}
+
+func TestConversion_ClickedLimit(t *testing.T) {
+	conversion := NewConversion().ClickedLimit(2)
+	links := []TrackableLink{
+		{TrackID: "t1", UserID: 1, Template: "sale", Variant: "a"},
+		{TrackID: "t2", UserID: 2, Template: "sale", Variant: "a"},
+		{TrackID: "t3", UserID: 3, Template: "sale", Variant: "a"},
+	}
+	for _, link := range links {
+		conversion.Clicked(link)
+	}
+	//t1 is forgotten, t3 is still remembered
+	conversion.Clicked(links[2])
+	conversion.Clicked(links[0])
+	if len(conversion.clicked) != 2 || conversion.Report("sale")[0].Clicked != 4 {
+		t.Fatalf("TestConversion_ClickedLimit failed. clicked:%d, report:%v\n", len(conversion.clicked), conversion.Report("sale"))
+	}
+}
+
+func TestTrackableLink_String(t *testing.T) {
+	link := NewTrackableLink(7, "abc")
+	if link.String() != "?user_id=7&track_id=abc" {
+		t.Fatalf("TestTrackableLink_String failed. link:%s\n", link)
+	}
+	link.Append, link.Template, link.Variant = true, "sale", "a b"
+	if link.String() != "&user_id=7&track_id=abc&template=sale&variant=a+b" {
+		t.Fatalf("TestTrackableLink_String failed. link:%s\n", link)
+	}
+}
+
+func TestTrackableLink_Apply(t *testing.T) {
+	link := TrackableLink{UserID: 7, TrackID: "abc", Template: "sale", Variant: "a"}
+	tracked, err := link.Apply("https://example.com/p?id=1&track_id=old#top")
+	if err != nil || tracked != "https://example.com/p?id=1&template=sale&track_id=abc&user_id=7&variant=a#top" {
+		t.Fatalf("TestTrackableLink_Apply failed. tracked:%s, err:%v\n", tracked, err)
+	}
+	link = TrackableLink{TrackID: "abc"}
+	content := link.Inject("go https://example.com/a, or http://example.com/b?x=1.")
+	if content != "go https://example.com/a?track_id=abc, or http://example.com/b?track_id=abc&x=1." {
+		t.Fatalf("TestTrackableLink_Apply failed. content:%s\n", content)
+	}
+	if TrackIDOf("sale", 35) != "sale.z" {
+		t.Fatalf("TestTrackableLink_Apply failed. track id:%s\n", TrackIDOf("sale", 35))
+	}
+}
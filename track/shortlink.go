@@ -0,0 +1,188 @@
+package track
+
+import (
+	"crypto/rand"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/linkedin-inc/mane/logger"
+)
+
+const (
+	codeAlphabet      = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	defaultCodeLength = 7
+	maxCodeAttempts   = 5
+)
+
+//urlPattern matches http(s) URLs up to the first character not allowed in a URL, e.g. a space or a chinese character
+var urlPattern = regexp.MustCompile(`https?://[A-Za-z0-9\-._~:/?#\[\]@!$&'()*+,;=%]+`)
+
+//trimURL cuts punctuation closing a sentence, it is not part of the URL
+func trimURL(match string) string {
+	return strings.TrimRight(match, ".,;:!?)'")
+}
+
+//ShortLink is what a short code stands for
+type ShortLink struct {
+	Code     string    `bson:"code" json:"code"`
+	URL      string    `bson:"url" json:"url"`
+	TrackID  string    `bson:"track_id" json:"track_id"`
+	UserID   int64     `bson:"user_id" json:"user_id"`
+	Template string    `bson:"template" json:"template"`
+	Variant  string    `bson:"variant,omitempty" json:"variant,omitempty"`
+	MsgID    int64     `bson:"msg_id" json:"msg_id"`
+	Token    string    `bson:"token,omitempty" json:"token,omitempty"`
+	Created  time.Time `bson:"created" json:"created"`
+}
+
+//Click is a visit of a short link
+type Click struct {
+	Code      string    `bson:"code" json:"code"`
+	TrackID   string    `bson:"track_id" json:"track_id"`
+	UserID    int64     `bson:"user_id" json:"user_id"`
+	Template  string    `bson:"template" json:"template"`
+	Variant   string    `bson:"variant,omitempty" json:"variant,omitempty"`
+	MsgID     int64     `bson:"msg_id" json:"msg_id"`
+	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
+	UserAgent string    `bson:"user_agent" json:"user_agent"`
+}
+
+//Shortener replaces URLs in messages with short links served by its Handler
+type Shortener struct {
+	store Store
+	//base is prepended to codes, e.g. https://t.example.com/
+	base       string
+	codeLength int
+	hooks      []func(click *Click)
+	signer     *Signer
+}
+
+//NewShortener serves short links under base, which must end with a slash
+func NewShortener(store Store, base string) *Shortener {
+	return &Shortener{store: store, base: base, codeLength: defaultCodeLength}
+}
+
+//OnClick adds a hook called for each click after it was saved, call it before serving
+func (s *Shortener) OnClick(hook func(click *Click)) *Shortener {
+	s.hooks = append(s.hooks, hook)
+	return s
+}
+
+//Verify makes the handler check the tokens of short links with signer, clicks on expired ones or ones signed with
+//a retired key are not recorded. The token is the one saved with the link, not one sent by the client: the random
+//code already keeps short links from being forged, so for them the token only bounds how long clicks count.
+func (s *Shortener) Verify(signer *Signer) *Shortener {
+	s.signer = signer
+	return s
+}
+
+//Shorten saves a short link to rawURL carrying the tracking data of link and msgID
+func (s *Shortener) Shorten(rawURL string, link TrackableLink, msgID int64) (string, error) {
+	shortLink := &ShortLink{
+		URL:      rawURL,
+		TrackID:  link.TrackID,
+		UserID:   link.UserID,
+		Template: link.Template,
+		Variant:  link.Variant,
+		MsgID:    msgID,
+		Token:    link.Token,
+		Created:  time.Now(),
+	}
+	var err error
+	for i := 0; i < maxCodeAttempts; i++ {
+		if shortLink.Code, err = s.newCode(); err != nil {
+			return "", err
+		}
+		if err = s.store.SaveLink(shortLink); err != ErrDuplicatedCode {
+			break
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return s.base + shortLink.Code, nil
+}
+
+func (s *Shortener) newCode() (string, error) {
+	code := make([]byte, s.codeLength)
+	max := big.NewInt(int64(len(codeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = codeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+//Rewrite replaces every URL in content with a short link, links already shortened are kept
+func (s *Shortener) Rewrite(content string, link TrackableLink, msgID int64) (string, error) {
+	var err error
+	rewritten := urlPattern.ReplaceAllStringFunc(content, func(match string) string {
+		if err != nil || strings.HasPrefix(match, s.base) {
+			return match
+		}
+		rawURL := trimURL(match)
+		var short string
+		if short, err = s.Shorten(rawURL, link, msgID); err != nil {
+			return match
+		}
+		return short + match[len(rawURL):]
+	})
+	if err != nil {
+		return content, err
+	}
+	return rewritten, nil
+}
+
+//Handler resolves short codes, records the click and redirects to the original URL
+func (s *Shortener) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		link, err := s.store.FindLink(code)
+		if err == ErrLinkNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			logger.Error("failed to find short link", logger.Any("code", code), logger.Err(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		click := &Click{
+			Code:      link.Code,
+			TrackID:   link.TrackID,
+			UserID:    link.UserID,
+			Template:  link.Template,
+			Variant:   link.Variant,
+			MsgID:     link.MsgID,
+			Timestamp: time.Now(),
+			UserAgent: r.UserAgent(),
+		}
+		//a click failed to be recorded must not stop the user
+		if err = s.verify(link); err != nil {
+			logger.Info("click not recorded", logger.Any("code", code), logger.Err(err))
+		} else if err = s.store.SaveClick(click); err != nil {
+			logger.Error("failed to save click", logger.Any("code", code), logger.Err(err))
+		} else {
+			for _, hook := range s.hooks {
+				hook(click)
+			}
+		}
+		http.Redirect(w, r, link.URL, http.StatusFound)
+	})
+}
+
+//verify checks the token saved with link when a signer is set, links saved without token fail then.
+//Only expiry and key retirement matter here, the server signed and stored the token itself.
+func (s *Shortener) verify(link *ShortLink) error {
+	if s.signer == nil {
+		return nil
+	}
+	_, _, err := s.signer.Verify(link.Token)
+	return err
+}
@@ -0,0 +1,55 @@
+package track
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestShortener(t *testing.T) {
+	store := NewMemoryStore()
+	shortener := NewShortener(store, "https://t.cn/")
+	conversion := NewConversion()
+	shortener.OnClick(conversion.ClickHook())
+
+	link := TrackableLink{TrackID: "spring", UserID: 42, Template: "sale", Variant: "a"}
+	content, err := shortener.Rewrite("打开 https://example.com/sale?from=sms#top。或 http://example.com/b. 已是 https://t.cn/abc", link, 1001)
+	if err != nil {
+		t.Fatalf("TestShortener failed. err:%v\n", err)
+	}
+	fields := strings.Fields(content)
+	first := strings.TrimSuffix(fields[1], "。或")
+	second := strings.TrimSuffix(fields[2], ".")
+	if !strings.HasPrefix(first, "https://t.cn/") || len(first) != len("https://t.cn/")+defaultCodeLength ||
+		!strings.HasPrefix(second, "https://t.cn/") || fields[4] != "https://t.cn/abc" {
+		t.Fatalf("TestShortener failed. content:%s\n", content)
+	}
+
+	server := httptest.NewServer(shortener.Handler())
+	defer server.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	request, _ := http.NewRequest("GET", server.URL+"/"+strings.TrimPrefix(first, "https://t.cn/"), nil)
+	request.Header.Set("User-Agent", "phone")
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatalf("TestShortener failed. err:%v\n", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusFound || response.Header.Get("Location") != "https://example.com/sale?from=sms#top" {
+		t.Fatalf("TestShortener failed. status:%d, location:%s\n", response.StatusCode, response.Header.Get("Location"))
+	}
+	clicks := store.Clicks()
+	if len(clicks) != 1 || clicks[0].UserID != 42 || clicks[0].MsgID != 1001 || clicks[0].Template != "sale" || clicks[0].UserAgent != "phone" {
+		t.Fatalf("TestShortener failed. clicks:%v\n", clicks)
+	}
+	if report := conversion.Report("sale"); len(report) != 1 || report[0].Clicked != 1 {
+		t.Fatalf("TestShortener failed. report:%v\n", report)
+	}
+
+	response, err = client.Get(server.URL + "/missing")
+	if err != nil || response.StatusCode != http.StatusNotFound {
+		t.Fatalf("TestShortener failed. err:%v\n", err)
+	}
+	response.Body.Close()
+}
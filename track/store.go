@@ -0,0 +1,68 @@
+package track
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	ErrDuplicatedCode = errors.New("duplicated short code")
+	ErrLinkNotFound   = errors.New("short link not found")
+)
+
+//Store keeps short links and the clicks on them, implement it on top of your own database
+type Store interface {
+	//SaveLink must fail with ErrDuplicatedCode if the code is taken
+	SaveLink(link *ShortLink) error
+	//FindLink must fail with ErrLinkNotFound if there is no link with the code
+	FindLink(code string) (*ShortLink, error)
+	SaveClick(click *Click) error
+}
+
+//MemoryStore keeps everything in memory, meant for tests
+type MemoryStore struct {
+	locker sync.RWMutex
+	links  map[string]*ShortLink
+	clicks []*Click
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{links: make(map[string]*ShortLink)}
+}
+
+func (s *MemoryStore) SaveLink(link *ShortLink) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if _, existed := s.links[link.Code]; existed {
+		return ErrDuplicatedCode
+	}
+	saved := *link
+	s.links[link.Code] = &saved
+	return nil
+}
+
+func (s *MemoryStore) FindLink(code string) (*ShortLink, error) {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	link, existed := s.links[code]
+	if !existed {
+		return nil, ErrLinkNotFound
+	}
+	found := *link
+	return &found, nil
+}
+
+func (s *MemoryStore) SaveClick(click *Click) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	saved := *click
+	s.clicks = append(s.clicks, &saved)
+	return nil
+}
+
+//Clicks returns every click saved, oldest first
+func (s *MemoryStore) Clicks() []*Click {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	return append([]*Click(nil), s.clicks...)
+}
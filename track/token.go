@@ -0,0 +1,163 @@
+package track
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	token = "token"
+	//signatures are truncated to keep links short in messages, 128 bits are still out of reach for forgery
+	signatureSize   = 16
+	defaultTokenTTL = 30 * 24 * time.Hour
+)
+
+var (
+	ErrMalformedToken   = errors.New("malformed tracking token")
+	ErrInvalidSignature = errors.New("invalid tracking token signature")
+	ErrTokenExpired     = errors.New("tracking token expired")
+	ErrUnknownKey       = errors.New("unknown tracking token key")
+	ErrEmptyKey         = errors.New("empty tracking token key")
+	ErrMalformedKeyID   = errors.New("tracking token key id must not contain a dot")
+)
+
+//Key signs tokens, ID is written into tokens so that they are verified with the key signing them
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+//claims is the payload of a token, keys are short to keep tokens short
+type claims struct {
+	UserID   int64  `json:"u,omitempty"`
+	TrackID  string `json:"t"`
+	Template string `json:"n,omitempty"`
+	Variant  string `json:"v,omitempty"`
+	MsgID    int64  `json:"m,omitempty"`
+	Expires  int64  `json:"e"`
+}
+
+//Signer issues tokens carrying the tracking data of links and verifies them, keys can be rotated at any time:
+//tokens are signed with the current key and verified with any key not retired yet.
+type Signer struct {
+	locker  sync.RWMutex
+	current string
+	keys    map[string][]byte
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+//NewSigner signs with key, tokens expire after ttl or after 30 days if ttl is 0
+func NewSigner(key Key, ttl time.Duration) (*Signer, error) {
+	if ttl == 0 {
+		ttl = defaultTokenTTL
+	}
+	s := &Signer{keys: make(map[string][]byte), ttl: ttl, now: time.Now}
+	if err := s.Rotate(key); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//Rotate signs new tokens with key, tokens signed with former keys are still verified until they are retired
+func (s *Signer) Rotate(key Key) error {
+	if key.ID == "" || len(key.Secret) == 0 {
+		return ErrEmptyKey
+	}
+	//the dot separates the parts of a token
+	if strings.Contains(key.ID, ".") {
+		return ErrMalformedKeyID
+	}
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.keys[key.ID] = key.Secret
+	s.current = key.ID
+	return nil
+}
+
+//Retire stops verifying tokens signed with the key of id, the current key can not be retired
+func (s *Signer) Retire(id string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if id != s.current {
+		delete(s.keys, id)
+	}
+}
+
+//Sign returns a token of link and msgID, the token is url safe
+func (s *Signer) Sign(link TrackableLink, msgID int64) (string, error) {
+	payload, err := json.Marshal(claims{
+		UserID:   link.UserID,
+		TrackID:  link.TrackID,
+		Template: link.Template,
+		Variant:  link.Variant,
+		MsgID:    msgID,
+		Expires:  s.now().Add(s.ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	s.locker.RLock()
+	id, secret := s.current, s.keys[s.current]
+	s.locker.RUnlock()
+	signed := id + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature(secret, signed)), nil
+}
+
+//Verify checks the signature and expiry of a token and returns the link and msg id it carries
+func (s *Signer) Verify(tokenString string) (TrackableLink, int64, error) {
+	parts := strings.Split(tokenString, ".")
+	if len(parts) != 3 {
+		return TrackableLink{}, 0, ErrMalformedToken
+	}
+	s.locker.RLock()
+	secret, existed := s.keys[parts[0]]
+	s.locker.RUnlock()
+	if !existed {
+		return TrackableLink{}, 0, ErrUnknownKey
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return TrackableLink{}, 0, ErrMalformedToken
+	}
+	if !hmac.Equal(sig, signature(secret, parts[0]+"."+parts[1])) {
+		return TrackableLink{}, 0, ErrInvalidSignature
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return TrackableLink{}, 0, ErrMalformedToken
+	}
+	var c claims
+	if err = json.Unmarshal(payload, &c); err != nil {
+		return TrackableLink{}, 0, ErrMalformedToken
+	}
+	if s.now().Unix() >= c.Expires {
+		return TrackableLink{}, 0, ErrTokenExpired
+	}
+	link := TrackableLink{
+		TrackID:  c.TrackID,
+		UserID:   c.UserID,
+		Template: c.Template,
+		Variant:  c.Variant,
+		Token:    tokenString,
+	}
+	return link, c.MsgID, nil
+}
+
+//VerifyRequest verifies the token param of a request to a tracked link, landing pages use it to count clicks
+func (s *Signer) VerifyRequest(r *http.Request) (TrackableLink, int64, error) {
+	return s.Verify(r.URL.Query().Get(token))
+}
+
+func signature(secret []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)[:signatureSize]
+}
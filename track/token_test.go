@@ -0,0 +1,99 @@
+package track
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	signer, err := NewSigner(Key{ID: "k1", Secret: []byte("first")}, time.Hour)
+	if err != nil {
+		t.Fatalf("TestSigner failed. err:%v\n", err)
+	}
+	link := TrackableLink{TrackID: "sale.1", UserID: 42, Template: "sale", Variant: "a"}
+	token, err := signer.Sign(link, 1001)
+	if err != nil {
+		t.Fatalf("TestSigner failed. err:%v\n", err)
+	}
+	verified, msgID, err := signer.Verify(token)
+	if err != nil || msgID != 1001 || verified.UserID != 42 || verified.TrackID != "sale.1" || verified.Template != "sale" || verified.Variant != "a" {
+		t.Fatalf("TestSigner failed. link:%+v, msg id:%d, err:%v\n", verified, msgID, err)
+	}
+
+	//a forged user keeps the signature of the original payload
+	forged := TrackableLink{TrackID: "sale.1", UserID: 43, Template: "sale", Variant: "a"}
+	other, _ := signer.Sign(forged, 1001)
+	parts, otherParts := strings.Split(token, "."), strings.Split(other, ".")
+	if _, _, err = signer.Verify(parts[0] + "." + otherParts[1] + "." + parts[2]); err != ErrInvalidSignature {
+		t.Fatalf("TestSigner failed. forged err:%v\n", err)
+	}
+	if _, _, err = signer.Verify("garbage"); err != ErrMalformedToken {
+		t.Fatalf("TestSigner failed. malformed err:%v\n", err)
+	}
+
+	//tokens of a rotated key are verified until it is retired
+	if err = signer.Rotate(Key{ID: "k2", Secret: []byte("second")}); err != nil {
+		t.Fatalf("TestSigner failed. err:%v\n", err)
+	}
+	if _, _, err = signer.Verify(token); err != nil {
+		t.Fatalf("TestSigner failed. rotated err:%v\n", err)
+	}
+	rotated, _ := signer.Sign(link, 1001)
+	if !strings.HasPrefix(rotated, "k2.") {
+		t.Fatalf("TestSigner failed. rotated token:%s\n", rotated)
+	}
+	signer.Retire("k1")
+	signer.Retire("k2")
+	if _, _, err = signer.Verify(token); err != ErrUnknownKey {
+		t.Fatalf("TestSigner failed. retired err:%v\n", err)
+	}
+	if _, _, err = signer.Verify(rotated); err != nil {
+		t.Fatalf("TestSigner failed. current key retired, err:%v\n", err)
+	}
+
+	signer.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, _, err = signer.Verify(rotated); err != ErrTokenExpired {
+		t.Fatalf("TestSigner failed. expired err:%v\n", err)
+	}
+	if _, err = NewSigner(Key{ID: "k3"}, 0); err != ErrEmptyKey {
+		t.Fatalf("TestSigner failed. empty key err:%v\n", err)
+	}
+	if err = signer.Rotate(Key{ID: "k.3", Secret: []byte("third")}); err != ErrMalformedKeyID {
+		t.Fatalf("TestSigner failed. dotted key id err:%v\n", err)
+	}
+}
+
+func TestSigner_Links(t *testing.T) {
+	signer, _ := NewSigner(Key{ID: "k1", Secret: []byte("first")}, time.Hour)
+	link := TrackableLink{TrackID: "sale.1", UserID: 42, Template: "sale", Variant: "a"}
+	link.Token, _ = signer.Sign(link, 1001)
+	tracked, err := link.Apply("https://example.com/p?id=1#top")
+	if err != nil || tracked != "https://example.com/p?id=1&token="+link.Token+"#top" {
+		t.Fatalf("TestSigner_Links failed. tracked:%s, err:%v\n", tracked, err)
+	}
+	//browsers do not send fragments
+	request := httptest.NewRequest("GET", strings.TrimSuffix(tracked, "#top"), nil)
+	if verified, msgID, err := signer.VerifyRequest(request); err != nil || verified.UserID != 42 || msgID != 1001 {
+		t.Fatalf("TestSigner_Links failed. link:%+v, err:%v\n", verified, err)
+	}
+
+	//clicks on short links with expired tokens still redirect but are not recorded
+	store := NewMemoryStore()
+	shortener := NewShortener(store, "https://t.cn/").Verify(signer)
+	short, _ := shortener.Shorten("https://example.com/p", link, 1001)
+	signer.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	response := httptest.NewRecorder()
+	shortener.Handler().ServeHTTP(response, httptest.NewRequest("GET", "/"+strings.TrimPrefix(short, "https://t.cn/"), nil))
+	if response.Code != http.StatusFound || len(store.Clicks()) != 0 {
+		t.Fatalf("TestSigner_Links failed. status:%d, clicks:%d\n", response.Code, len(store.Clicks()))
+	}
+	signer.now = time.Now
+	response = httptest.NewRecorder()
+	shortener.Handler().ServeHTTP(response, httptest.NewRequest("GET", "/"+strings.TrimPrefix(short, "https://t.cn/"), nil))
+	if response.Code != http.StatusFound || len(store.Clicks()) != 1 {
+		t.Fatalf("TestSigner_Links failed. status:%d, clicks:%d\n", response.Code, len(store.Clicks()))
+	}
+}
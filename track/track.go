@@ -1,16 +1,27 @@
 package track
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
 
 const (
-	trackID = "track_id"
-	userID  = "user_id"
+	trackID  = "track_id"
+	userID   = "user_id"
+	template = "template"
+	variant  = "variant"
 )
 
 type TrackableLink struct {
 	TrackID string
 	UserID  int64
 	Append  bool
+	//Template and Variant attribute clicks to a template variant, they are left out of the link when Variant is empty
+	Template string
+	Variant  string
+	//Token is a signed token of the fields above, when set it replaces them in links so that they can not be forged
+	Token string
 }
 
 func NewTrackableLink(userID int64, trackID string) TrackableLink {
@@ -22,11 +33,57 @@ func NewTrackableLink(userID int64, trackID string) TrackableLink {
 }
 
 func (l TrackableLink) String() string {
+	query := fmt.Sprintf(userID+"=%d"+"&"+trackID+"=%s", l.UserID, l.TrackID)
+	if l.Variant != "" {
+		query += "&" + template + "=" + url.QueryEscape(l.Template) + "&" + variant + "=" + url.QueryEscape(l.Variant)
+	}
 	if l.Append {
 		//append mode
-		return fmt.Sprintf("&"+userID+"=%d"+"&"+trackID+"=%s", l.UserID, l.TrackID)
+		return "&" + query
+	}
+	return "?" + query
+}
+
+//Apply merges the tracking params into rawURL, keeping its other params and fragment. Append is not needed.
+//user_id is left out when UserID is 0, e.g. when one content is sent to many users, only token is set when Token is.
+func (l TrackableLink) Apply(rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL, err
+	}
+	query := parsed.Query()
+	if l.Token != "" {
+		query.Set(token, l.Token)
+		parsed.RawQuery = query.Encode()
+		return parsed.String(), nil
 	}
-	return fmt.Sprintf("?"+userID+"=%d"+"&"+trackID+"=%s", l.UserID, l.TrackID)
+	if l.UserID != 0 {
+		query.Set(userID, strconv.FormatInt(l.UserID, 10))
+	}
+	query.Set(trackID, l.TrackID)
+	if l.Variant != "" {
+		query.Set(template, l.Template)
+		query.Set(variant, l.Variant)
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+//Inject applies the tracking params to every URL in content, URLs failing to parse are left untouched
+func (l TrackableLink) Inject(content string) string {
+	return urlPattern.ReplaceAllStringFunc(content, func(match string) string {
+		rawURL := trimURL(match)
+		tracked, err := l.Apply(rawURL)
+		if err != nil {
+			return match
+		}
+		return tracked + match[len(rawURL):]
+	})
+}
+
+//TrackIDOf derives the track id of a message from its template and msg id
+func TrackIDOf(template string, msgID int64) string {
+	return template + "." + strconv.FormatInt(msgID, 36)
 }
 
 //GenerateTrackableLink return a trackable uri for given userID and trackID
@@ -0,0 +1,94 @@
+package util
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter bounds how many requests may be in flight at once and how fast new ones may start.
+// A single Limiter is meant to be shared by every caller talking to the same endpoint,
+// a nil Limiter never blocks.
+type Limiter struct {
+	slots    chan struct{}
+	interval time.Duration
+	locker   sync.Mutex
+	next     time.Time
+}
+
+// Will make a limiter.
+// maxInFlight - how many requests may be running at the same time, <= 0 means unlimited
+// qps - how many requests may start per second, <= 0 means unlimited
+func NewLimiter(maxInFlight int, qps int) *Limiter {
+	l := &Limiter{}
+	if maxInFlight > 0 {
+		l.slots = make(chan struct{}, maxInFlight)
+	}
+	if qps > 0 {
+		l.interval = time.Second / time.Duration(qps)
+	}
+	return l
+}
+
+// Will block until a slot is free and the rate allows another request to start.
+// Every Acquire must be paired with a Release.
+func (l *Limiter) Acquire() {
+	_ = l.AcquireContext(context.Background())
+}
+
+// Will block like Acquire, but give up with the error of ctx once it is done.
+// A Release must follow only when it succeeded.
+func (l *Limiter) AcquireContext(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if l.interval == 0 {
+		return nil
+	}
+	l.locker.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.locker.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		//give the start reserved back, so a cancelled request does not delay the ones after it
+		l.locker.Lock()
+		l.next = l.next.Add(-l.interval)
+		l.locker.Unlock()
+		l.Release()
+		return ctx.Err()
+	}
+}
+
+// Will give back the slot taken by Acquire.
+func (l *Limiter) Release() {
+	if l != nil && l.slots != nil {
+		<-l.slots
+	}
+}
+
+// How many requests are in flight right now.
+func (l *Limiter) InFlight() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.slots)
+}
@@ -0,0 +1,89 @@
+package util
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiter_MaxInFlight(t *testing.T) {
+	limiter := NewLimiter(3, 0)
+	var inFlight, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limiter.Acquire()
+			defer limiter.Release()
+			current := atomic.AddInt32(&inFlight, 1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+		}()
+	}
+	wg.Wait()
+	if peak > 3 {
+		t.Errorf("TestLimiter_MaxInFlight failed. peak:%d\n", peak)
+	}
+	if limiter.InFlight() != 0 {
+		t.Errorf("TestLimiter_MaxInFlight failed. in flight after release:%d\n", limiter.InFlight())
+	}
+}
+
+func TestLimiter_QPS(t *testing.T) {
+	limiter := NewLimiter(0, 100)
+	begin := time.Now()
+	for i := 0; i < 11; i++ {
+		limiter.Acquire()
+		limiter.Release()
+	}
+	// the first request starts immediately, the other 10 are spaced by 10ms
+	if elapsed := time.Since(begin); elapsed < 90*time.Millisecond {
+		t.Errorf("TestLimiter_QPS failed. elapsed:%v\n", elapsed)
+	}
+}
+
+func TestLimiter_Cancel(t *testing.T) {
+	limiter := NewLimiter(1, 0)
+	limiter.Acquire()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.AcquireContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("TestLimiter_Cancel failed. slot err:%v\n", err)
+	}
+	limiter.Release()
+
+	limiter = NewLimiter(1, 1)
+	limiter.Acquire()
+	limiter.Release()
+	next := limiter.next
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	if err := limiter.AcquireContext(ctx); err != context.DeadlineExceeded || time.Since(begin) > 500*time.Millisecond {
+		t.Fatalf("TestLimiter_Cancel failed. rate err:%v, waited:%v\n", err, time.Since(begin))
+	}
+	if limiter.InFlight() != 0 {
+		t.Fatalf("TestLimiter_Cancel failed. slot kept:%d\n", limiter.InFlight())
+	}
+	if !limiter.next.Equal(next) {
+		t.Fatalf("TestLimiter_Cancel failed. reservation kept, next:%v, expected:%v\n", limiter.next, next)
+	}
+}
+
+func TestLimiter_Nil(t *testing.T) {
+	var limiter *Limiter
+	limiter.Acquire()
+	limiter.Release()
+	if limiter.InFlight() != 0 {
+		t.Error("TestLimiter_Nil failed")
+	}
+}
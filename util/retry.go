@@ -0,0 +1,148 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// ErrorCategory tells what kind of failure an error represents, a RetryPolicy decides by it whether to try again.
+type ErrorCategory int
+
+const (
+	// the request was understood and rejected, trying again won't help
+	ErrorPermanent ErrorCategory = 0
+	// the request didn't reach the remote or the response got lost
+	ErrorNetwork ErrorCategory = 1 << iota
+	// the remote answered with http 5xx
+	ErrorServer
+	// the remote answered with its own internal error code
+	ErrorVendor
+	// the remote refused because of too many requests
+	ErrorThrottled
+
+	ErrorTransient = ErrorNetwork | ErrorServer | ErrorVendor | ErrorThrottled
+)
+
+// CategorizedError attaches an ErrorCategory to an error.
+type CategorizedError struct {
+	Category ErrorCategory
+	Err      error
+}
+
+func (e *CategorizedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CategorizedError) Unwrap() error {
+	return e.Err
+}
+
+// Categorize wraps err with category, nil stays nil.
+func Categorize(category ErrorCategory, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &CategorizedError{Category: category, Err: err}
+}
+
+// CategoryOf returns the category attached to err, uncategorized errors are permanent.
+func CategoryOf(err error) ErrorCategory {
+	var categorized *CategorizedError
+	if errors.As(err, &categorized) {
+		return categorized.Category
+	}
+	return ErrorPermanent
+}
+
+// StatusCategory returns the category of a non 200 http status code.
+func StatusCategory(code int) ErrorCategory {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return ErrorThrottled
+	case code >= 500:
+		return ErrorServer
+	default:
+		return ErrorPermanent
+	}
+}
+
+// RetryPolicy describes how a failed call should be tried again.
+// The zero value makes exactly one attempt.
+type RetryPolicy struct {
+	// how many times the call is made at most, including the first one
+	MaxAttempts int
+	// wait before the 2nd attempt
+	InitialBackoff time.Duration
+	// upper bound of the wait between two attempts
+	MaxBackoff time.Duration
+	// the wait grows by this factor after each attempt, values below 1 are treated as 1
+	Multiplier float64
+	// each wait is randomly shortened or lengthened by up to this fraction, e.g. 0.2 means ±20%
+	Jitter float64
+	// which categories of errors are worth another attempt
+	Retryable ErrorCategory
+	// no attempt starts later than this after the first one, 0 means no deadline
+	Deadline time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: time.Second,
+	MaxBackoff:     8 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+	Retryable:      ErrorTransient,
+	Deadline:       30 * time.Second,
+}
+
+// Do calls call until it succeeds, returns an error not worth retrying, the policy is exhausted or ctx is done.
+// attempt starts from 0, the error of the last attempt is returned, or ctx.Err() if ctx was done before an attempt.
+func (p RetryPolicy) Do(ctx context.Context, call func(attempt int) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	begin := time.Now()
+	backoff := p.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := call(attempt)
+		if err == nil {
+			return nil
+		}
+		if attempt+1 >= p.MaxAttempts || p.Retryable&CategoryOf(err) == 0 {
+			return err
+		}
+		wait := p.jitter(backoff)
+		if p.Deadline > 0 && time.Since(begin)+wait > p.Deadline {
+			return err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		backoff = p.next(backoff)
+	}
+}
+
+func (p RetryPolicy) next(backoff time.Duration) time.Duration {
+	if p.Multiplier > 1 {
+		backoff = time.Duration(float64(backoff) * p.Multiplier)
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
+}
+
+func (p RetryPolicy) jitter(backoff time.Duration) time.Duration {
+	if p.Jitter <= 0 || backoff <= 0 {
+		return backoff
+	}
+	delta := (rand.Float64()*2 - 1) * p.Jitter * float64(backoff)
+	return backoff + time.Duration(delta)
+}
@@ -0,0 +1,105 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errTest = errors.New("test")
+
+func TestRetryPolicy_Do(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Millisecond, Multiplier: 2, Retryable: ErrorNetwork}
+	calls := 0
+	err := policy.Do(context.Background(), func(attempt int) error {
+		calls++
+		if attempt < 2 {
+			return Categorize(ErrorNetwork, errTest)
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("TestRetryPolicy_Do failed. calls:%d, err:%v\n", calls, err)
+	}
+}
+
+func TestRetryPolicy_DoExhausted(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Retryable: ErrorTransient}
+	calls := 0
+	err := policy.Do(context.Background(), func(attempt int) error {
+		calls++
+		return Categorize(ErrorServer, errTest)
+	})
+	if !errors.Is(err, errTest) || calls != 3 {
+		t.Errorf("TestRetryPolicy_DoExhausted failed. calls:%d, err:%v\n", calls, err)
+	}
+}
+
+func TestRetryPolicy_DoNotRetryable(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Retryable: ErrorNetwork}
+	calls := 0
+	_ = policy.Do(context.Background(), func(attempt int) error {
+		calls++
+		return Categorize(ErrorVendor, errTest)
+	})
+	if calls != 1 {
+		t.Errorf("TestRetryPolicy_DoNotRetryable failed. calls:%d\n", calls)
+	}
+	calls = 0
+	_ = policy.Do(context.Background(), func(attempt int) error {
+		calls++
+		return errTest
+	})
+	if calls != 1 {
+		t.Errorf("TestRetryPolicy_DoNotRetryable uncategorized failed. calls:%d\n", calls)
+	}
+}
+
+func TestRetryPolicy_Deadline(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, InitialBackoff: 20 * time.Millisecond, Retryable: ErrorTransient, Deadline: 50 * time.Millisecond}
+	calls := 0
+	_ = policy.Do(context.Background(), func(attempt int) error {
+		calls++
+		return Categorize(ErrorNetwork, errTest)
+	})
+	// attempts start at about 0ms, 20ms and 40ms
+	if calls < 2 || calls > 3 {
+		t.Errorf("TestRetryPolicy_Deadline failed. calls:%d\n", calls)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 3 * time.Second, Multiplier: 2, Jitter: 0.5}
+	if next := policy.next(time.Second); next != 2*time.Second {
+		t.Errorf("TestRetryPolicy_Backoff failed. next:%v\n", next)
+	}
+	if next := policy.next(2 * time.Second); next != 3*time.Second {
+		t.Errorf("TestRetryPolicy_Backoff capped failed. next:%v\n", next)
+	}
+	for i := 0; i < 100; i++ {
+		if wait := policy.jitter(time.Second); wait < 500*time.Millisecond || wait > 1500*time.Millisecond {
+			t.Fatalf("TestRetryPolicy_Backoff jitter failed. wait:%v\n", wait)
+		}
+	}
+}
+
+func TestRetryPolicy_Cancel(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Hour, Retryable: ErrorTransient}
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	err := policy.Do(ctx, func(attempt int) error {
+		calls++
+		return Categorize(ErrorNetwork, errTest)
+	})
+	if err != context.Canceled || calls != 1 {
+		t.Errorf("TestRetryPolicy_Cancel failed. calls:%d, err:%v\n", calls, err)
+	}
+	if err = policy.Do(ctx, func(attempt int) error { calls++; return nil }); err != context.Canceled || calls != 1 {
+		t.Errorf("TestRetryPolicy_Cancel done failed. calls:%d, err:%v\n", calls, err)
+	}
+}
@@ -0,0 +1,232 @@
+package util
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Executor runs tasks, both Pool and SharedPool are one.
+type Executor interface {
+	Submit(ctx context.Context, task func()) error
+}
+
+type quotaKeysKey struct{}
+
+// WithQuotaKeys attaches keys to ctx, a SharedPool counts tasks submitted with ctx against the quotas of these keys.
+func WithQuotaKeys(ctx context.Context, keys ...string) context.Context {
+	merged := append(append([]string(nil), QuotaKeys(ctx)...), keys...)
+	return context.WithValue(ctx, quotaKeysKey{}, merged)
+}
+
+// QuotaKeys returns the keys attached to ctx.
+func QuotaKeys(ctx context.Context) []string {
+	keys, _ := ctx.Value(quotaKeysKey{}).([]string)
+	return keys
+}
+
+type priorityKey struct{}
+
+// WithPriority makes tasks submitted with ctx start before waiting tasks of lower priority, the default is 0.
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// PriorityOf returns the priority attached to ctx.
+func PriorityOf(ctx context.Context) int {
+	priority, _ := ctx.Value(priorityKey{}).(int)
+	return priority
+}
+
+type waiter struct {
+	keys     []string
+	priority int
+	//ready is closed once the task may start, or with err set once it never will
+	ready chan struct{}
+	err   error
+}
+
+// SharedPool is meant to be shared by the whole process, it bounds how many tasks run at once in total
+// and per quota key, e.g. per vendor and per channel. Waiting tasks start by priority then in submission order,
+// a task held back by its quota does not hold back tasks with other keys.
+// Slots reserved for a key are kept free of tasks without it, so that its tasks start right away.
+type SharedPool struct {
+	locker   sync.Mutex
+	size     int
+	running  int
+	quotas   map[string]int
+	reserves map[string]int
+	inUse    map[string]int
+	waiters  []*waiter
+	released bool
+	tasks    sync.WaitGroup
+}
+
+// Will make a pool running at most size tasks at once.
+func NewSharedPool(size int) *SharedPool {
+	return &SharedPool{
+		size:     size,
+		quotas:   make(map[string]int),
+		reserves: make(map[string]int),
+		inUse:    make(map[string]int),
+	}
+}
+
+// Will limit the tasks running at once with key to max, <= 0 removes the limit.
+func (p *SharedPool) SetQuota(key string, max int) *SharedPool {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	if max <= 0 {
+		delete(p.quotas, key)
+	} else {
+		p.quotas[key] = max
+	}
+	p.wake()
+	return p
+}
+
+// Will keep n slots for tasks with key, tasks without it never take them, <= 0 removes the reserve.
+func (p *SharedPool) SetReserve(key string, n int) *SharedPool {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	if n <= 0 {
+		delete(p.reserves, key)
+	} else {
+		p.reserves[key] = n
+	}
+	p.wake()
+	return p
+}
+
+// Will wait until the total, the quotas and the reserves allow another task with the keys of ctx, then start task.
+// It fails with the error of ctx if it is done first, and with ErrPoolReleased once Release was called.
+func (p *SharedPool) Submit(ctx context.Context, task func()) error {
+	keys := QuotaKeys(ctx)
+	p.locker.Lock()
+	if p.released {
+		p.locker.Unlock()
+		return ErrPoolReleased
+	}
+	w := &waiter{keys: keys, priority: PriorityOf(ctx), ready: make(chan struct{})}
+	p.waiters = append(p.waiters, w)
+	p.wake()
+	p.locker.Unlock()
+
+	select {
+	case <-w.ready:
+		if w.err != nil {
+			return w.err
+		}
+	case <-ctx.Done():
+		p.locker.Lock()
+		select {
+		case <-w.ready:
+			//started meanwhile, give the slot back
+			if w.err == nil {
+				p.finish(keys)
+			}
+		default:
+			p.remove(w)
+		}
+		p.locker.Unlock()
+		return ctx.Err()
+	}
+	go func() {
+		defer func() {
+			p.locker.Lock()
+			p.finish(keys)
+			p.locker.Unlock()
+		}()
+		task()
+	}()
+	return nil
+}
+
+// Will stop accepting tasks, fail the waiting ones with ErrPoolReleased and wait for the running ones to finish,
+// it is safe to call more than once.
+func (p *SharedPool) Release() {
+	p.locker.Lock()
+	p.released = true
+	for _, w := range p.waiters {
+		w.err = ErrPoolReleased
+		close(w.ready)
+	}
+	p.waiters = nil
+	p.locker.Unlock()
+	p.tasks.Wait()
+}
+
+// How many tasks with key are running right now.
+func (p *SharedPool) Running(key string) int {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	return p.inUse[key]
+}
+
+func (p *SharedPool) fits(keys []string) bool {
+	if p.running >= p.size {
+		return false
+	}
+	owned := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if max, limited := p.quotas[key]; limited && p.inUse[key] >= max {
+			return false
+		}
+		owned[key] = true
+	}
+	// the slots still reserved for other keys are out of reach
+	kept := 0
+	for key, reserve := range p.reserves {
+		if !owned[key] && p.inUse[key] < reserve {
+			kept += reserve - p.inUse[key]
+		}
+	}
+	return p.size-p.running > kept
+}
+
+// wake starts every waiter that fits by priority, the caller must hold the lock
+func (p *SharedPool) wake() {
+	if p.released {
+		return
+	}
+	sort.SliceStable(p.waiters, func(i, j int) bool {
+		return p.waiters[i].priority > p.waiters[j].priority
+	})
+	remaining := p.waiters[:0]
+	for _, w := range p.waiters {
+		if !p.fits(w.keys) {
+			remaining = append(remaining, w)
+			continue
+		}
+		p.running++
+		for _, key := range w.keys {
+			p.inUse[key]++
+		}
+		p.tasks.Add(1)
+		close(w.ready)
+	}
+	for i := len(remaining); i < len(p.waiters); i++ {
+		p.waiters[i] = nil
+	}
+	p.waiters = remaining
+}
+
+// finish gives back the slots of a task, the caller must hold the lock
+func (p *SharedPool) finish(keys []string) {
+	p.running--
+	for _, key := range keys {
+		p.inUse[key]--
+	}
+	p.tasks.Done()
+	p.wake()
+}
+
+// remove drops a waiter that gave up, the caller must hold the lock
+func (p *SharedPool) remove(w *waiter) {
+	for i := range p.waiters {
+		if p.waiters[i] == w {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			return
+		}
+	}
+}
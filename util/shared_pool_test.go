@@ -0,0 +1,190 @@
+package util
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSharedPool(t *testing.T) {
+	pool := NewSharedPool(4).SetQuota("channel:marketing", 2)
+	defer pool.Release()
+	marketing := WithQuotaKeys(context.Background(), "channel:marketing")
+	production := WithQuotaKeys(context.Background(), "channel:production")
+
+	release := make(chan struct{})
+	var peak int32
+	var wg sync.WaitGroup
+	//a blast of marketing tasks queued first
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := pool.Submit(marketing, func() {
+				if running := int32(pool.Running("channel:marketing")); running > atomic.LoadInt32(&peak) {
+					atomic.StoreInt32(&peak, running)
+				}
+				<-release
+			})
+			if err != nil {
+				t.Errorf("TestSharedPool failed. err:%v\n", err)
+			}
+		}()
+	}
+	for pool.Running("channel:marketing") < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	//production is not starved by the waiting marketing tasks
+	done := make(chan struct{})
+	if err := pool.Submit(production, func() { close(done) }); err != nil {
+		t.Fatalf("TestSharedPool failed. err:%v\n", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("TestSharedPool failed. production task starved\n")
+	}
+	close(release)
+	wg.Wait()
+	if atomic.LoadInt32(&peak) > 2 {
+		t.Fatalf("TestSharedPool failed. peak:%d\n", peak)
+	}
+}
+
+func TestSharedPool_Cancel(t *testing.T) {
+	pool := NewSharedPool(1)
+	release := make(chan struct{})
+	if err := pool.Submit(context.Background(), func() { <-release }); err != nil {
+		t.Fatalf("TestSharedPool_Cancel failed. err:%v\n", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pool.Submit(ctx, func() {}); err != context.DeadlineExceeded {
+		t.Fatalf("TestSharedPool_Cancel failed. err:%v\n", err)
+	}
+	close(release)
+	//a group over the shared pool collects results like over a Pool
+	group := NewGroup[int](context.Background(), pool)
+	for i := 0; i < 3; i++ {
+		value := i
+		group.Go(func(ctx context.Context) (int, error) { return value, nil })
+	}
+	results, err := group.Wait()
+	if err != nil || len(results) != 3 || results[2].Value != 2 {
+		t.Fatalf("TestSharedPool_Cancel failed. results:%v, err:%v\n", results, err)
+	}
+	pool.Release()
+	if err = pool.Submit(context.Background(), func() {}); err != ErrPoolReleased {
+		t.Fatalf("TestSharedPool_Cancel failed. err:%v\n", err)
+	}
+}
+
+func TestSharedPool_Release(t *testing.T) {
+	pool := NewSharedPool(1)
+	release := make(chan struct{})
+	if err := pool.Submit(context.Background(), func() { <-release }); err != nil {
+		t.Fatalf("TestSharedPool_Release failed. err:%v\n", err)
+	}
+	var ran int32
+	queued := make(chan error)
+	go func() {
+		queued <- pool.Submit(context.Background(), func() { atomic.AddInt32(&ran, 1) })
+	}()
+	for {
+		pool.locker.Lock()
+		waiting := len(pool.waiters)
+		pool.locker.Unlock()
+		if waiting == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	released := make(chan struct{})
+	go func() {
+		pool.Release()
+		close(released)
+	}()
+	//the queued task fails at once, while Release waits for the running one
+	if err := <-queued; err != ErrPoolReleased {
+		t.Fatalf("TestSharedPool_Release failed. err:%v\n", err)
+	}
+	close(release)
+	<-released
+	if atomic.LoadInt32(&ran) != 0 {
+		t.Fatal("TestSharedPool_Release failed. queued task ran after Release")
+	}
+}
+
+func TestSharedPool_Priority(t *testing.T) {
+	pool := NewSharedPool(2).SetReserve("channel:production", 1)
+	defer pool.Release()
+	marketing := WithQuotaKeys(context.Background(), "channel:marketing")
+	production := WithPriority(WithQuotaKeys(context.Background(), "channel:production"), 2)
+
+	release := make(chan struct{})
+	if err := pool.Submit(marketing, func() { <-release }); err != nil {
+		t.Fatalf("TestSharedPool_Priority failed. err:%v\n", err)
+	}
+	//the last slot is reserved for production
+	ctx, cancel := context.WithTimeout(marketing, 10*time.Millisecond)
+	defer cancel()
+	if err := pool.Submit(ctx, func() {}); err != context.DeadlineExceeded {
+		t.Fatalf("TestSharedPool_Priority failed. err:%v\n", err)
+	}
+	holdProduction := make(chan struct{})
+	if err := pool.Submit(production, func() { <-holdProduction }); err != nil {
+		t.Fatalf("TestSharedPool_Priority failed. err:%v\n", err)
+	}
+
+	//queued production tasks start before marketing tasks queued earlier
+	var locker sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	submit := func(ctx context.Context, name string) {
+		defer wg.Done()
+		_ = pool.Submit(ctx, func() {
+			locker.Lock()
+			order = append(order, name)
+			locker.Unlock()
+		})
+	}
+	wg.Add(2)
+	go submit(marketing, "marketing")
+	for {
+		pool.locker.Lock()
+		queued := len(pool.waiters)
+		pool.locker.Unlock()
+		if queued == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	go submit(production, "production")
+	for {
+		pool.locker.Lock()
+		queued := len(pool.waiters)
+		pool.locker.Unlock()
+		if queued == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	for {
+		locker.Lock()
+		started := len(order)
+		locker.Unlock()
+		if started > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(holdProduction)
+	wg.Wait()
+	pool.Release()
+	if len(order) != 2 || order[0] != "production" {
+		t.Fatalf("TestSharedPool_Priority failed. order:%v\n", order)
+	}
+}
@@ -13,7 +13,7 @@ func Atoi64Safe(s string, x int64) int64 {
 	}
 	i, err := strconv.ParseInt(s, 10, 0)
 	if err != nil {
-		logger.E("[panic] parse atoi64 s:%s, err:%v\n", s, err)
+		logger.Error("failed to parse int64", logger.Any("value", s), logger.Err(err))
 		return x
 	}
 	return i
@@ -38,7 +38,7 @@ func Atoi32Safe(s string, defaultVal int32) int32 {
 
 	i, err := strconv.ParseInt(s, 10, 32)
 	if err != nil {
-		logger.E("[panic] parse atoi32 s:%s, err:%v\n", s, err)
+		logger.Error("failed to parse int32", logger.Any("value", s), logger.Err(err))
 		return defaultVal
 	}
 	return int32(i)
@@ -48,6 +48,7 @@ func Itoa(i int) (s string) {
 	return strconv.FormatInt(int64(i), 10)
 }
 
+// Deprecated: mane decides by config.RunMode, CHITU_ENV only picks the run mode used until config.SetRunMode is called.
 func IsProduction() bool {
 	return os.Getenv("CHITU_ENV") == "production"
 }
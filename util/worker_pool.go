@@ -1,134 +1,155 @@
 package util
 
-import "sync"
-
-// Gorouting instance which can accept client jobs
-type worker struct {
-	workerPool chan *worker
-	jobChannel chan Job
-	stop       chan bool
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+var ErrPoolReleased = errors.New("pool released")
+
+// PanicError is returned for a job that panicked, the pool and the other jobs keep going.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
 }
 
-func (w *worker) start() {
-	go func() {
-		var job Job
-		for {
-			// worker free, add it to pool
-			w.workerPool <- w
-
-			select {
-			case job = <-w.jobChannel:
-				job()
-			case stop := <-w.stop:
-				if stop {
-					w.stop <- true
-					return
-				}
-			}
-		}
-	}()
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("job panicked: %v", e.Value)
 }
 
-func newWorker(pool chan *worker) *worker {
-	return &worker{
-		workerPool: pool,
-		jobChannel: make(chan Job),
-		stop:       make(chan bool),
-	}
+// Pool runs tasks on a fixed set of goroutines, tasks beyond the queue length wait for room when submitted.
+type Pool struct {
+	tasks    chan func()
+	locker   sync.RWMutex
+	released bool
+	workers  sync.WaitGroup
 }
 
-// Accepts jobs from clients, and waits for first free worker to deliver job
-type dispatcher struct {
-	workerPool chan *worker
-	jobQueue   chan Job
-	stop       chan bool
+// Will make pool of gorouting workers.
+// numWorkers - how many workers will be created for this pool
+// queueLen - how many tasks can we accept until Submit blocks
+func NewPool(numWorkers int, queueLen int) *Pool {
+	p := &Pool{tasks: make(chan func(), queueLen)}
+	p.workers.Add(numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		go p.work()
+	}
+	return p
 }
 
-func (d *dispatcher) dispatch() {
-	for {
-		select {
-		case job := <-d.jobQueue:
-			worker := <-d.workerPool
-			worker.jobChannel <- job
-		case stop := <-d.stop:
-			if stop {
-				for i := 0; i < cap(d.workerPool); i++ {
-					worker := <-d.workerPool
-
-					worker.stop <- true
-					<-worker.stop
-				}
-
-				d.stop <- true
-				return
-			}
-		}
+func (p *Pool) work() {
+	defer p.workers.Done()
+	for task := range p.tasks {
+		task()
 	}
 }
 
-func newDispatcher(workerPool chan *worker, jobQueue chan Job) *dispatcher {
-	d := &dispatcher{
-		workerPool: workerPool,
-		jobQueue:   jobQueue,
-		stop:       make(chan bool),
+// Will queue task, waiting for room until ctx is done.
+// It fails with ErrPoolReleased once Release was called.
+// A task must not submit to its own pool and wait for the result, that can exhaust the workers.
+func (p *Pool) Submit(ctx context.Context, task func()) error {
+	p.locker.RLock()
+	defer p.locker.RUnlock()
+	if p.released {
+		return ErrPoolReleased
 	}
-
-	for i := 0; i < cap(d.workerPool); i++ {
-		worker := newWorker(d.workerPool)
-		worker.start()
+	select {
+	case p.tasks <- task:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
 
-	go d.dispatch()
-	return d
+// Will stop accepting tasks and wait for the queued ones to finish, it is safe to call more than once.
+func (p *Pool) Release() {
+	p.locker.Lock()
+	if !p.released {
+		p.released = true
+		close(p.tasks)
+	}
+	p.locker.Unlock()
+	p.workers.Wait()
 }
 
-// Represents user request, function which should be executed in some worker.
-type Job func()
+// Job is a unit of work run by a Group, it should give up once ctx is done.
+type Job[T any] func(ctx context.Context) (T, error)
 
-type Pool struct {
-	JobQueue   chan Job
-	dispatcher *dispatcher
-	wg         sync.WaitGroup
+// Result is the outcome of the job submitted at Index.
+type Result[T any] struct {
+	Index int
+	Value T
+	Err   error
 }
 
-// Will make pool of gorouting workers.
-// numWorkers - how many workers will be created for this pool
-// queueLen - how many jobs can we accept until we block
-//
-// Returned object contains JobQueue reference, which you can use to send job to pool.
-func NewPool(numWorkers int, jobQueueLen int) *Pool {
-	jobQueue := make(chan Job, jobQueueLen)
-	workerPool := make(chan *worker, numWorkers)
-
-	pool := &Pool{
-		JobQueue:   jobQueue,
-		dispatcher: newDispatcher(workerPool, jobQueue),
-	}
+// Group runs jobs on an executor and collects their results, replacing manual WaitGroup bookkeeping.
+// Jobs not started yet when ctx is done fail with the error of ctx without running.
+type Group[T any] struct {
+	ctx      context.Context
+	executor Executor
+	pending  sync.WaitGroup
+	locker   sync.Mutex
+	results  []Result[T]
+}
 
-	return pool
+func NewGroup[T any](ctx context.Context, executor Executor) *Group[T] {
+	return &Group[T]{ctx: ctx, executor: executor}
 }
 
-// In case you are using WaitAll fn, you should call this method
-// every time your job is done.
-//
-// If you are not using WaitAll then we assume you have your own way of synchronizing.
-func (p *Pool) JobDone() {
-	p.wg.Done()
+// Will submit job, waiting for the executor to accept it.
+func (g *Group[T]) Go(job Job[T]) {
+	g.locker.Lock()
+	index := len(g.results)
+	g.results = append(g.results, Result[T]{Index: index})
+	g.locker.Unlock()
+	g.pending.Add(1)
+	err := g.executor.Submit(g.ctx, func() {
+		defer g.pending.Done()
+		value, err := g.run(job)
+		g.set(index, value, err)
+	})
+	if err != nil {
+		var zero T
+		g.set(index, zero, err)
+		g.pending.Done()
+	}
 }
 
-// How many jobs we should wait when calling WaitAll.
-// It is using WaitGroup Add/Done/Wait
-func (p *Pool) WaitCount(count int) {
-	p.wg.Add(count)
+func (g *Group[T]) run(job Job[T]) (value T, err error) {
+	if err = g.ctx.Err(); err != nil {
+		return value, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return job(g.ctx)
 }
 
-// Will wait for all jobs to finish.
-func (p *Pool) WaitAll() {
-	p.wg.Wait()
+func (g *Group[T]) set(index int, value T, err error) {
+	g.locker.Lock()
+	defer g.locker.Unlock()
+	g.results[index].Value = value
+	g.results[index].Err = err
 }
 
-// Will release resources used by pool
-func (p *Pool) Release() {
-	p.dispatcher.stop <- true
-	<-p.dispatcher.stop
+// Will wait for every job submitted so far and return their results in submission order,
+// along with the errors of the failed ones joined together.
+func (g *Group[T]) Wait() ([]Result[T], error) {
+	g.pending.Wait()
+	g.locker.Lock()
+	defer g.locker.Unlock()
+	results := make([]Result[T], len(g.results))
+	copy(results, g.results)
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+	}
+	return results, errors.Join(errs...)
 }
@@ -1,25 +1,124 @@
 package util
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
-var pool *Pool
+var errOdd = errors.New("odd")
 
 func TestPool(t *testing.T) {
-	pool = NewPool(10, 10)
+	pool := NewPool(10, 10)
 	defer pool.Release()
 
 	testCount := 100
-	pool.WaitCount(testCount)
+	group := NewGroup[int](context.Background(), pool)
 	for i := 0; i < testCount; i++ {
 		count := i
-		pool.JobQueue <- func() {
-			defer pool.JobDone()
-			fmt.Printf("hello %d\n", count)
+		group.Go(func(ctx context.Context) (int, error) {
+			if count%2 == 1 {
+				return 0, errOdd
+			}
+			if count == 50 {
+				panic("boom")
+			}
+			return count * 2, nil
+		})
+	}
+	results, err := group.Wait()
+	if len(results) != testCount || !errors.Is(err, errOdd) {
+		t.Fatalf("TestPool failed. results:%d, err:%v\n", len(results), err)
+	}
+	for i, result := range results {
+		var panicked *PanicError
+		switch {
+		case result.Index != i:
+			t.Fatalf("TestPool failed. result %d has index %d\n", i, result.Index)
+		case i == 50:
+			if !errors.As(result.Err, &panicked) || panicked.Value != "boom" {
+				t.Fatalf("TestPool failed. panic err:%v\n", result.Err)
+			}
+		case i%2 == 1:
+			if result.Err != errOdd {
+				t.Fatalf("TestPool failed. result %d err:%v\n", i, result.Err)
+			}
+		case result.Err != nil || result.Value != i*2:
+			t.Fatalf("TestPool failed. result %d:%+v\n", i, result)
+		}
+	}
+}
+
+func TestPool_Cancel(t *testing.T) {
+	pool := NewPool(1, 10)
+	defer pool.Release()
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	var ran int32
+	group := NewGroup[struct{}](ctx, pool)
+	group.Go(func(ctx context.Context) (struct{}, error) {
+		close(started)
+		<-ctx.Done()
+		return struct{}{}, ctx.Err()
+	})
+	for i := 0; i < 5; i++ {
+		group.Go(func(ctx context.Context) (struct{}, error) {
+			atomic.AddInt32(&ran, 1)
+			return struct{}{}, nil
+		})
+	}
+	<-started
+	cancel()
+	results, err := group.Wait()
+	if !errors.Is(err, context.Canceled) || atomic.LoadInt32(&ran) != 0 {
+		t.Fatalf("TestPool_Cancel failed. ran:%d, err:%v\n", ran, err)
+	}
+	for _, result := range results {
+		if result.Err != context.Canceled {
+			t.Fatalf("TestPool_Cancel failed. result:%+v\n", result)
 		}
 	}
-	pool.WaitAll()
-	fmt.Println("done")
+
+	//a full queue gives up once ctx is done
+	blocked := NewPool(1, 0)
+	release := make(chan struct{})
+	if err = blocked.Submit(context.Background(), func() { <-release }); err != nil {
+		t.Fatalf("TestPool_Cancel failed. err:%v\n", err)
+	}
+	timeout, cancelTimeout := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelTimeout()
+	if err = blocked.Submit(timeout, func() {}); err != context.DeadlineExceeded {
+		t.Fatalf("TestPool_Cancel failed. full queue err:%v\n", err)
+	}
+	close(release)
+	blocked.Release()
+}
+
+func TestPool_Release(t *testing.T) {
+	pool := NewPool(2, 10)
+	var done int32
+	for i := 0; i < 10; i++ {
+		if err := pool.Submit(context.Background(), func() {
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&done, 1)
+		}); err != nil {
+			t.Fatalf("TestPool_Release failed. err:%v\n", err)
+		}
+	}
+	//queued tasks are run before Release returns
+	pool.Release()
+	if atomic.LoadInt32(&done) != 10 {
+		t.Fatalf("TestPool_Release failed. done:%d\n", done)
+	}
+	pool.Release()
+	if err := pool.Submit(context.Background(), func() {}); err != ErrPoolReleased {
+		t.Fatalf("TestPool_Release failed. err:%v\n", err)
+	}
+	group := NewGroup[int](context.Background(), pool)
+	group.Go(func(ctx context.Context) (int, error) { return 1, nil })
+	if _, err := group.Wait(); !errors.Is(err, ErrPoolReleased) {
+		t.Fatalf("TestPool_Release failed. group err:%v\n", err)
+	}
 }